@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/joho/godotenv"
@@ -20,8 +21,28 @@ type Config struct {
 	EmailImapPort string
 	EmailSmtpHost string
 	EmailSmtpPort string
+
+	// Optional settings
+	ComposeInEditor bool
+	SaveSent        bool
+	SentMailbox     string
+	DraftsMailbox   string
+	TrashMailbox    string
+	ArchiveMailbox  string
+	AttachmentDir   string
+	OpenCommand     string
+	SenderNameOnly  bool
+	SmtpTLS         string
 }
 
+// How the SMTP connection is encrypted: implicit TLS, STARTTLS, or chosen
+// by port, with implicit TLS on 465 and STARTTLS elsewhere
+const (
+	SmtpTLSAuto     = "auto"
+	SmtpTLSImplicit = "tls"
+	SmtpTLSStartTLS = "starttls"
+)
+
 // GetConfigDir returns the user's config directory for the app
 func GetConfigDir() (string, error) {
 	homeDir, err := os.UserHomeDir()
@@ -129,6 +150,29 @@ func LoadConfig() (*Config, error) {
 		EmailSmtpPort: os.Getenv("EMAIL_SMTP_PORT"),
 	}
 
+	// Optional settings fall back to their defaults when unset or invalid
+	config.ComposeInEditor, _ = strconv.ParseBool(os.Getenv("EMAIL_COMPOSE_IN_EDITOR"))
+	config.SaveSent = true
+	if v, err := strconv.ParseBool(os.Getenv("EMAIL_SAVE_SENT")); err == nil {
+		config.SaveSent = v
+	}
+	config.SentMailbox = os.Getenv("EMAIL_SENT_MAILBOX")
+	config.DraftsMailbox = os.Getenv("EMAIL_DRAFTS_MAILBOX")
+	config.TrashMailbox = os.Getenv("EMAIL_TRASH_MAILBOX")
+	config.ArchiveMailbox = os.Getenv("EMAIL_ARCHIVE_MAILBOX")
+	config.AttachmentDir = os.Getenv("EMAIL_ATTACHMENT_DIR")
+	if config.AttachmentDir == "" {
+		config.AttachmentDir = "~/Downloads"
+	}
+	config.OpenCommand = os.Getenv("EMAIL_OPEN_COMMAND")
+	config.SenderNameOnly, _ = strconv.ParseBool(os.Getenv("EMAIL_SENDER_NAME_ONLY"))
+	switch v := strings.ToLower(os.Getenv("EMAIL_SMTP_TLS")); v {
+	case SmtpTLSImplicit, SmtpTLSStartTLS:
+		config.SmtpTLS = v
+	default:
+		config.SmtpTLS = SmtpTLSAuto
+	}
+
 	// Validate required fields
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
@@ -0,0 +1,79 @@
+package email
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-imap"
+)
+
+// Address is a mailbox with its optional display name
+type Address struct {
+	Name string
+	Addr string
+}
+
+// String formats the address as "Name <addr>", or just the address when
+// there is no display name. Names with special characters are quoted so the
+// result can be typed back into a recipient field.
+func (a Address) String() string {
+	if a.Name == "" || a.Name == a.Addr {
+		return a.Addr
+	}
+	name := a.Name
+	if strings.ContainsAny(name, `,;:"<>@()[]\`) {
+		name = strconv.Quote(name)
+	}
+	return name + " <" + a.Addr + ">"
+}
+
+// Display returns the display name, falling back to the address
+func (a Address) Display() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.Addr
+}
+
+// Equal reports whether two addresses name the same mailbox
+func (a Address) Equal(b Address) bool {
+	return strings.EqualFold(a.Addr, b.Addr)
+}
+
+// FormatAddresses joins a list of addresses for display
+func FormatAddresses(addrs []Address) string {
+	return strings.Join(addressStrings(addrs), ", ")
+}
+
+// addressStrings formats every address in a list
+func addressStrings(addrs []Address) []string {
+	var list []string
+	for _, a := range addrs {
+		list = append(list, a.String())
+	}
+	return list
+}
+
+// addressList converts envelope addresses, decoding their display names.
+// Group markers, which have no host, are skipped.
+func addressList(addrs []*imap.Address) []Address {
+	var list []Address
+	for _, a := range addrs {
+		if a == nil || a.HostName == "" {
+			continue
+		}
+		list = append(list, Address{
+			Name: strings.TrimSpace(DecodeHeader(a.PersonalName)),
+			Addr: a.Address(),
+		})
+	}
+	return list
+}
+
+// firstAddress returns the first address of an envelope list, if any
+func firstAddress(addrs []*imap.Address) Address {
+	if list := addressList(addrs); len(list) > 0 {
+		return list[0]
+	}
+	return Address{}
+}
@@ -0,0 +1,256 @@
+package email
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// Attachment describes a message part listed in the BODYSTRUCTURE, which is
+// enough to show it without downloading its content
+type Attachment struct {
+	// Part number path, e.g. [2 1] for BODY[2.1]
+	Part     []int
+	Filename string
+	MIMEType string
+	Size     uint32
+	Encoding string
+}
+
+// attachmentsOf lists the parts of a body structure that are attachments
+// rather than the readable body
+func attachmentsOf(bs *imap.BodyStructure) []Attachment {
+	if bs == nil {
+		return nil
+	}
+
+	var atts []Attachment
+	bs.Walk(func(path []int, part *imap.BodyStructure) bool {
+		if strings.EqualFold(part.MIMEType, "multipart") {
+			return true
+		}
+
+		mimeType := strings.ToLower(part.MIMEType + "/" + part.MIMESubType)
+		filename, _ := part.Filename()
+
+		// Unnamed text and inline parts make up the readable body
+		if filename == "" && part.Disposition != "attachment" &&
+			(strings.EqualFold(part.MIMEType, "text") || part.Disposition == "inline") {
+			return false
+		}
+
+		// Names come from the sender, so clean them before they are shown
+		filename = SanitizeFilename(filename)
+		if filename == "attachment" {
+			filename = "attachment-" + partSpec(path)
+			if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+				filename += exts[0]
+			}
+		}
+
+		atts = append(atts, Attachment{
+			Part:     append([]int(nil), path...),
+			Filename: filename,
+			MIMEType: mimeType,
+			Size:     part.Size,
+			Encoding: strings.ToLower(part.Encoding),
+		})
+		return false
+	})
+	return atts
+}
+
+// partSpec formats a part path the way IMAP writes it, e.g. "2.1"
+func partSpec(path []int) string {
+	parts := make([]string, len(path))
+	for i, n := range path {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// FormatSize renders a byte count for display
+func FormatSize(size uint32) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.0f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// SaveAttachments downloads attachments of a message into dir and returns
+// the paths they were written to. Existing files are never overwritten.
+func (s *Session) SaveAttachments(mailbox string, uid uint32, atts []Attachment, dir string) ([]string, error) {
+	dir, err := ExpandHome(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	var paths []string
+	err = s.Do(func(c *client.Client) error {
+		if _, err := s.selectForUIDs(c, mailbox); err != nil {
+			return err
+		}
+
+		paths = nil
+		for _, att := range atts {
+			path, err := saveAttachment(c, uid, att, dir)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// saveAttachment streams one decoded attachment to a new file in dir
+func saveAttachment(c *client.Client, uid uint32, att Attachment, dir string) (string, error) {
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uid)
+
+	section := &imap.BodySectionName{
+		BodyPartName: imap.BodyPartName{Path: att.Part},
+		Peek:         true,
+	}
+	items := []imap.FetchItem{section.FetchItem()}
+
+	messages := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.UidFetch(seqSet, items, messages)
+	}()
+
+	var path string
+	var writeErr error
+	for msg := range messages {
+		r := msg.GetBody(section)
+		if r == nil || writeErr != nil {
+			continue
+		}
+		path, writeErr = writeAttachment(dir, att, decodePart(r, att.Encoding))
+	}
+
+	if err := <-done; err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", att.Filename, err)
+	}
+	if writeErr != nil {
+		return "", writeErr
+	}
+	if path == "" {
+		return "", fmt.Errorf("attachment %s not found", att.Filename)
+	}
+	return path, nil
+}
+
+// decodePart undoes the part's content transfer encoding
+func decodePart(r io.Reader, encoding string) io.Reader {
+	switch encoding {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// writeAttachment creates a file for the attachment under a safe, unused name
+func writeAttachment(dir string, att Attachment, r io.Reader) (string, error) {
+	name := SanitizeFilename(att.Filename)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 0; ; i++ {
+		path := filepath.Join(dir, name)
+		if i > 0 {
+			path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+		}
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", path, err)
+		}
+
+		_, err = io.Copy(f, r)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		return path, nil
+	}
+}
+
+// SanitizeFilename turns a sender-chosen attachment name into a plain file
+// name that cannot escape the target directory
+func SanitizeFilename(name string) string {
+	// Drop any directory part, whichever separator the sender used
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+
+	// Leading dots would hide the file or name a parent directory
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+
+	// Keep well under the usual 255 byte limit, preserving the extension
+	const maxLen = 200
+	if len(name) > maxLen {
+		ext := filepath.Ext(name)
+		if len(ext) > 20 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:maxLen-len(ext)], "") + ext
+	}
+
+	if name == "" {
+		return "attachment"
+	}
+	return name
+}
+
+// ExpandHome replaces a leading ~ with the user's home directory
+func ExpandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
@@ -0,0 +1,70 @@
+package email
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// Number of message bodies kept in memory
+const bodyCacheSize = 50
+
+// bodyCache is a small LRU of recently opened message bodies
+type bodyCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type bodyCacheEntry struct {
+	key  string
+	body string
+}
+
+func newBodyCache(capacity int) *bodyCache {
+	return &bodyCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// bodyKey identifies a message body across mailboxes. UIDs are only unique
+// within one UIDVALIDITY, so it is part of the key.
+func bodyKey(mailbox string, validity, uid uint32) string {
+	return fmt.Sprintf("%s:%d:%d", mailbox, validity, uid)
+}
+
+// Get returns a cached body and marks it as recently used
+func (bc *bodyCache) Get(key string) (string, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	el, ok := bc.entries[key]
+	if !ok {
+		return "", false
+	}
+	bc.order.MoveToFront(el)
+	return el.Value.(*bodyCacheEntry).body, true
+}
+
+// Put stores a body, evicting the least recently used one when full
+func (bc *bodyCache) Put(key, body string) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if el, ok := bc.entries[key]; ok {
+		el.Value.(*bodyCacheEntry).body = body
+		bc.order.MoveToFront(el)
+		return
+	}
+
+	bc.entries[key] = bc.order.PushFront(&bodyCacheEntry{key: key, body: body})
+
+	if bc.order.Len() > bc.capacity {
+		oldest := bc.order.Back()
+		bc.order.Remove(oldest)
+		delete(bc.entries, oldest.Value.(*bodyCacheEntry).key)
+	}
+}
@@ -0,0 +1,65 @@
+package email
+
+import (
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/charset"
+	htmlcharset "golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func init() {
+	// Importing go-message's charset package registers every golang.org/x/text
+	// encoding with go-message; the IMAP envelope parser needs it separately
+	imap.CharsetReader = charset.Reader
+}
+
+// wordDecoder decodes RFC 2047 encoded-words in any supported charset
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.Reader}
+
+// DecodeHeader decodes RFC 2047 encoded-words such as =?ISO-8859-1?Q?...?=,
+// returning the text unchanged if it cannot be decoded
+func DecodeHeader(s string) string {
+	if !strings.Contains(s, "=?") {
+		return s
+	}
+	decoded, err := wordDecoder.DecodeHeader(s)
+	if err != nil {
+		return s
+	}
+	return decoded
+}
+
+// parseErr reports whether an error from reading a message is fatal. An
+// unknown charset is not: the part is still readable, just undecoded.
+func parseErr(err error) bool {
+	return err != nil && !message.IsUnknownCharset(err)
+}
+
+// decodeText turns the content of a text part into a UTF-8 string. Parts
+// with a known charset have already been converted by go-message; for the
+// rest the charset of an HTML part is sniffed from its meta tags, and
+// Windows-1252, the usual culprit behind unlabelled 8-bit mail, is assumed
+// for anything that is not valid UTF-8.
+func decodeText(b []byte, mediaType string) string {
+	if mediaType == "text/html" && !utf8.Valid(b) {
+		enc, _, _ := htmlcharset.DetermineEncoding(b, "text/html")
+		if decoded, err := enc.NewDecoder().Bytes(b); err == nil {
+			return string(decoded)
+		}
+	}
+
+	if utf8.Valid(b) {
+		return string(b)
+	}
+
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(b)
+	if err != nil {
+		return strings.ToValidUTF8(string(b), "�")
+	}
+	return string(decoded)
+}
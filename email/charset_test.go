@@ -0,0 +1,119 @@
+package email
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-message/textproto"
+)
+
+// readFixture opens a message from testdata
+func readFixture(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to open fixture: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestCharsetFixtures(t *testing.T) {
+	tests := []struct {
+		file    string
+		from    string
+		subject string
+		body    string
+	}{
+		{
+			file:    "iso-8859-1.eml",
+			from:    "André Dupont <andre@example.fr>",
+			subject: "Réunion du comité",
+			body:    "Café crème à la française, naïve façade.",
+		},
+		{
+			file:    "windows-1252.eml",
+			from:    "“Shop” <shop@example.com>",
+			subject: "Sale – 50€ off",
+			body:    "Price: 50€ – “smart quotes” and the ‘end’.",
+		},
+		{
+			file:    "shift_jis.eml",
+			from:    "山田太郎 <yamada@example.jp>",
+			subject: "会議のお知らせ",
+			body:    "こんにちは、世界。会議は明日です。",
+		},
+		{
+			file:    "gb2312.eml",
+			from:    "王小明 <wang@example.cn>",
+			subject: "会议通知",
+			body:    "你好，欢迎参加会议。",
+		},
+		{
+			file:    "koi8-r.eml",
+			from:    "Иван Петров <ivan@example.ru>",
+			subject: "Встреча",
+			body:    "Привет, мир! Встреча в пятницу.",
+		},
+		{
+			file:    "html-meta-charset.eml",
+			from:    "billing@example.fr",
+			subject: "Facture",
+			body:    "Le coût est de 20 €.",
+		},
+		{
+			file:    "unlabelled-8bit.eml",
+			from:    "old@example.com",
+			subject: "Legacy client",
+			body:    "Grüße aus Köln",
+		},
+		{
+			file:    "unknown-charset.eml",
+			from:    "x@example.com",
+			subject: "=?x-unknown?Q?abc?=",
+			body:    "Plain ASCII survives.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			h, err := textproto.ReadHeader(bufio.NewReader(readFixture(t, tt.file)))
+			if err != nil {
+				t.Fatalf("failed to read header: %v", err)
+			}
+			if got := DecodeHeader(h.Get("From")); got != tt.from {
+				t.Errorf("From = %q, want %q", got, tt.from)
+			}
+			if got := DecodeHeader(h.Get("Subject")); got != tt.subject {
+				t.Errorf("Subject = %q, want %q", got, tt.subject)
+			}
+
+			body := parseBody(readFixture(t, tt.file))
+			if !strings.Contains(body, tt.body) {
+				t.Errorf("body = %q, want it to contain %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{"=?UTF-8?B?w6l0w6k=?=", "été"},
+		{"=?iso-8859-1?q?caf=E9?= au lait", "café au lait"},
+		// Adjacent encoded-words are joined without the space between them
+		{"=?UTF-8?Q?a?= =?UTF-8?Q?b?=", "ab"},
+		{"=?broken", "=?broken"},
+	}
+
+	for _, tt := range tests {
+		if got := DecodeHeader(tt.in); got != tt.want {
+			t.Errorf("DecodeHeader(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
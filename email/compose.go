@@ -0,0 +1,294 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message/mail"
+)
+
+// Words that suggest the sender meant to attach something
+var attachmentWords = regexp.MustCompile(`(?i)\b(attach(ed|ing|ment|ments)?|enclosed)\b`)
+
+// OutgoingMessage is a message written in the compose view. Recipient
+// lists hold addresses as typed, e.g. "Alice <alice@example.com>".
+type OutgoingMessage struct {
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+	Body    string
+
+	// Threading headers for replies, Message-IDs without angle brackets
+	InReplyTo  string
+	References []string
+
+	// Kept stable across draft saves so older versions can be replaced
+	MessageID string
+
+	// Paths of local files to attach
+	Attachments []string
+}
+
+// GenerateMessageID returns a new unique Message-ID without angle brackets
+func GenerateMessageID() string {
+	var h mail.Header
+	if err := h.GenerateMessageID(); err != nil {
+		return ""
+	}
+	id, _ := h.MessageID()
+	return id
+}
+
+// SplitAddresses turns a comma separated recipient field into a list,
+// dropping empty entries. Commas inside quoted names or angle brackets do
+// not split.
+func SplitAddresses(field string) []string {
+	var addrs []string
+	var cur strings.Builder
+	quoted, angle, escaped := false, false, false
+
+	flush := func() {
+		if a := strings.TrimSpace(cur.String()); a != "" {
+			addrs = append(addrs, a)
+		}
+		cur.Reset()
+	}
+
+	for _, r := range field {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && r == '<':
+			angle = true
+		case !quoted && r == '>':
+			angle = false
+		case !quoted && !angle && r == ',':
+			flush()
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	flush()
+
+	return addrs
+}
+
+// Recipients returns the bare addresses of every To, Cc and Bcc recipient
+func (m *OutgoingMessage) Recipients() ([]string, error) {
+	var rcpts []string
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		addrs, err := parseAddresses(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			rcpts = append(rcpts, a.Address)
+		}
+	}
+
+	if len(rcpts) == 0 {
+		return nil, fmt.Errorf("no recipients")
+	}
+	return rcpts, nil
+}
+
+// Build renders the message as RFC 5322 text sent from the given address.
+// Bcc recipients are left out of the headers.
+func (m *OutgoingMessage) Build(from string) ([]byte, error) {
+	return m.build(from, false)
+}
+
+// BuildDraft renders the message for the Drafts mailbox, keeping Bcc so it
+// survives being resumed
+func (m *OutgoingMessage) BuildDraft(from string) ([]byte, error) {
+	return m.build(from, true)
+}
+
+func (m *OutgoingMessage) build(from string, withBcc bool) ([]byte, error) {
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", from, err)
+	}
+	to, err := parseAddresses(m.To)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := parseAddresses(m.Cc)
+	if err != nil {
+		return nil, err
+	}
+	bcc, err := parseAddresses(m.Bcc)
+	if err != nil {
+		return nil, err
+	}
+
+	var h mail.Header
+	h.SetDate(time.Now())
+	h.SetAddressList("From", []*mail.Address{fromAddr})
+	h.SetAddressList("To", to)
+	if len(cc) > 0 {
+		h.SetAddressList("Cc", cc)
+	}
+	if withBcc && len(bcc) > 0 {
+		h.SetAddressList("Bcc", bcc)
+	}
+	h.SetSubject(m.Subject)
+	if m.InReplyTo != "" {
+		h.SetMsgIDList("In-Reply-To", []string{m.InReplyTo})
+	}
+	if len(m.References) > 0 {
+		h.SetMsgIDList("References", m.References)
+	}
+	if m.MessageID != "" {
+		h.SetMessageID(m.MessageID)
+	} else if err := h.GenerateMessageID(); err != nil {
+		return nil, fmt.Errorf("failed to generate Message-ID: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := m.writeBody(&buf, h); err != nil {
+		return nil, fmt.Errorf("failed to finish message: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MentionsAttachment reports whether the body talks about an attachment
+// while nothing is attached. Quoted lines from a reply are ignored.
+func (m *OutgoingMessage) MentionsAttachment() bool {
+	if len(m.Attachments) > 0 {
+		return false
+	}
+	for _, line := range strings.Split(m.Body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ">") {
+			continue
+		}
+		if attachmentWords.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectMIMEType guesses a file's type from its extension, falling back to
+// sniffing its first bytes
+func DetectMIMEType(path string) (string, error) {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		// Parameters such as charset are dropped, only the type is wanted
+		if mediaType, _, err := mime.ParseMediaType(t); err == nil {
+			return mediaType, nil
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	return mediaType, nil
+}
+
+// writeBody writes the text part, alone or followed by the attachments in
+// a multipart/mixed message. The text is quoted-printable so non-ASCII
+// characters and long lines survive relays without 8BITMIME.
+func (m *OutgoingMessage) writeBody(buf *bytes.Buffer, h mail.Header) error {
+	if len(m.Attachments) == 0 {
+		h.SetContentType("text/plain", map[string]string{"charset": "utf-8"})
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := mail.CreateSingleInlineWriter(buf, h)
+		if err != nil {
+			return fmt.Errorf("failed to create message: %w", err)
+		}
+		if _, err := w.Write([]byte(m.Body)); err != nil {
+			return fmt.Errorf("failed to write message body: %w", err)
+		}
+		return w.Close()
+	}
+
+	mw, err := mail.CreateWriter(buf, h)
+	if err != nil {
+		return fmt.Errorf("failed to create message: %w", err)
+	}
+
+	var th mail.InlineHeader
+	th.SetContentType("text/plain", map[string]string{"charset": "utf-8"})
+	th.Set("Content-Transfer-Encoding", "quoted-printable")
+	tw, err := mw.CreateSingleInline(th)
+	if err != nil {
+		return fmt.Errorf("failed to create message body: %w", err)
+	}
+	if _, err := tw.Write([]byte(m.Body)); err != nil {
+		return fmt.Errorf("failed to write message body: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write message body: %w", err)
+	}
+
+	for _, path := range m.Attachments {
+		if err := writeAttachmentPart(mw, path); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// writeAttachmentPart adds one local file as a base64 attachment
+func writeAttachmentPart(mw *mail.Writer, path string) error {
+	mimeType, err := DetectMIMEType(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open attachment %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var ah mail.AttachmentHeader
+	ah.SetContentType(mimeType, nil)
+	ah.SetFilename(filepath.Base(path))
+	ah.Set("Content-Transfer-Encoding", "base64")
+
+	w, err := mw.CreateAttachment(ah)
+	if err != nil {
+		return fmt.Errorf("failed to add attachment %s: %w", path, err)
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("failed to read attachment %s: %w", path, err)
+	}
+	return w.Close()
+}
+
+// parseAddresses validates a list of typed addresses
+func parseAddresses(list []string) ([]*mail.Address, error) {
+	var addrs []*mail.Address
+	for _, a := range list {
+		addr, err := mail.ParseAddress(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", a, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
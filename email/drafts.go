@@ -0,0 +1,260 @@
+package email
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-message/mail"
+)
+
+// DraftsMailbox returns the configured Drafts mailbox or discovers it
+// through the \Drafts special-use attribute
+func (s *Session) DraftsMailbox() (string, error) {
+	return s.configuredMailbox(s.cfg.DraftsMailbox, "EMAIL_DRAFTS_MAILBOX", imap.DraftsAttr)
+}
+
+// SaveDraft stores the message in the Drafts mailbox, replacing any earlier
+// version saved under the same Message-ID
+func (s *Session) SaveDraft(msg *OutgoingMessage) error {
+	if msg.MessageID == "" {
+		return fmt.Errorf("draft has no Message-ID")
+	}
+
+	drafts, err := s.DraftsMailbox()
+	if err != nil {
+		return err
+	}
+
+	raw, err := msg.BuildDraft(s.cfg.EmailUsername)
+	if err != nil {
+		return err
+	}
+
+	return s.Do(func(c *client.Client) error {
+		// Find the previous versions before adding the new one
+		if _, err := selectMailbox(c, drafts); err != nil {
+			return err
+		}
+		old, err := searchMessageID(c, msg.MessageID)
+		if err != nil {
+			return err
+		}
+
+		if err := appendMessage(c, drafts, []string{imap.DraftFlag, imap.SeenFlag}, raw); err != nil {
+			return err
+		}
+
+		// The new version is saved either way; when other messages in
+		// Drafts are marked deleted, the old versions are left marked for
+		// whoever expunges next rather than removing the others as well
+		if err := deleteUIDs(c, old); err != nil && !errors.Is(err, ErrOtherDeleted) {
+			return err
+		}
+		return nil
+	})
+}
+
+// DeleteDraft removes every saved version of a draft, along with the
+// attachments unpacked when it was resumed
+func (s *Session) DeleteDraft(messageID string) error {
+	if dir, err := draftFilesDir(messageID); err == nil {
+		os.RemoveAll(dir)
+	}
+
+	drafts, err := s.DraftsMailbox()
+	if err != nil {
+		return err
+	}
+
+	return s.Do(func(c *client.Client) error {
+		if _, err := selectMailbox(c, drafts); err != nil {
+			return err
+		}
+		uids, err := searchMessageID(c, messageID)
+		if err != nil {
+			return err
+		}
+		return deleteUIDs(c, uids)
+	})
+}
+
+// FetchDraft downloads a saved draft so it can be resumed in the compose view
+func (s *Session) FetchDraft(mailbox string, uid uint32) (*OutgoingMessage, error) {
+	var draft *OutgoingMessage
+	err := s.Do(func(c *client.Client) error {
+		if _, err := s.selectForUIDs(c, mailbox); err != nil {
+			return err
+		}
+
+		seqSet := new(imap.SeqSet)
+		seqSet.AddNum(uid)
+
+		section := &imap.BodySectionName{Peek: true}
+		items := []imap.FetchItem{section.FetchItem()}
+
+		messages := make(chan *imap.Message, 1)
+		done := make(chan error, 1)
+
+		go func() {
+			done <- c.UidFetch(seqSet, items, messages)
+		}()
+
+		var parseErr error
+		for msg := range messages {
+			if r := msg.GetBody(section); r != nil {
+				draft, parseErr = parseDraft(r)
+			}
+		}
+
+		if err := <-done; err != nil {
+			return fmt.Errorf("failed to fetch draft %d: %w", uid, err)
+		}
+		if parseErr != nil {
+			return parseErr
+		}
+		if draft == nil {
+			return fmt.Errorf("draft %d not found in %s", uid, mailbox)
+		}
+		return nil
+	})
+	return draft, err
+}
+
+// parseDraft turns a raw saved draft back into an outgoing message. Its
+// attachments are unpacked into the draft's directory under the user cache
+// directory, so they are attached again when it is saved or sent.
+func parseDraft(r io.Reader) (*OutgoingMessage, error) {
+	mr, err := mail.CreateReader(r)
+	if parseErr(err) {
+		return nil, fmt.Errorf("failed to parse draft: %w", err)
+	}
+
+	h := mr.Header
+	draft := &OutgoingMessage{}
+	draft.To = headerAddresses(h, "To")
+	draft.Cc = headerAddresses(h, "Cc")
+	draft.Bcc = headerAddresses(h, "Bcc")
+	draft.Subject, _ = h.Subject()
+	draft.MessageID, _ = h.MessageID()
+	if draft.MessageID == "" {
+		draft.MessageID = GenerateMessageID()
+	}
+	if ids, err := h.MsgIDList("In-Reply-To"); err == nil && len(ids) > 0 {
+		draft.InReplyTo = ids[0]
+	}
+	draft.References, _ = h.MsgIDList("References")
+
+	dir, err := draftFilesDir(draft.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to clear %s: %w", dir, err)
+	}
+
+	haveBody := false
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF || parseErr(err) {
+			break
+		}
+		switch ph := p.Header.(type) {
+		case *mail.InlineHeader:
+			ct, _, _ := ph.ContentType()
+			if !haveBody && (ct == "text/plain" || ct == "") {
+				b, _ := io.ReadAll(p.Body)
+				draft.Body = decodeText(b, ct)
+				haveBody = true
+			}
+		case *mail.AttachmentHeader:
+			name, _ := ph.Filename()
+			path, err := saveDraftFile(dir, name, len(draft.Attachments), p.Body)
+			if err != nil {
+				return nil, err
+			}
+			draft.Attachments = append(draft.Attachments, path)
+		}
+	}
+
+	return draft, nil
+}
+
+// draftFilesDir returns the directory a draft's attachments are unpacked
+// into. It is named after a hash of the Message-ID, which may hold
+// characters that are not safe in paths.
+func draftFilesDir(messageID string) (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find cache directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(messageID))
+	return filepath.Join(cache, "GoMail", "drafts", hex.EncodeToString(sum[:8])), nil
+}
+
+// saveDraftFile writes one attachment of a draft into dir, keeping its
+// name. Each file gets a numbered subdirectory so equal names do not clash.
+func saveDraftFile(dir, name string, index int, r io.Reader) (string, error) {
+	name = filepath.Base(name)
+	if name == "." || name == string(filepath.Separator) || name == "" {
+		name = "attachment"
+	}
+
+	sub := filepath.Join(dir, strconv.Itoa(index+1))
+	if err := os.MkdirAll(sub, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", sub, err)
+	}
+
+	path := filepath.Join(sub, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// headerAddresses returns an address header as a list of typed addresses
+func headerAddresses(h mail.Header, key string) []string {
+	addrs, err := h.AddressList(key)
+	if err != nil {
+		return nil
+	}
+
+	var list []string
+	for _, a := range addrs {
+		if a.Name == "" {
+			list = append(list, a.Address)
+		} else {
+			list = append(list, a.String())
+		}
+	}
+	return list
+}
+
+// searchMessageID finds the UIDs of messages with the given Message-ID in
+// the selected mailbox, including versions already marked deleted so they
+// are expunged along with the rest
+func searchMessageID(c *client.Client, messageID string) ([]uint32, error) {
+	criteria := imap.NewSearchCriteria()
+	criteria.Header.Add("Message-ID", "<"+messageID+">")
+
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for %s: %w", messageID, err)
+	}
+	return uids, nil
+}
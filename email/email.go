@@ -1,24 +1,93 @@
 package email
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/Zachkp/GoMail/config"
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-message"
 	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
 	"golang.org/x/net/html"
 )
 
-// Email represents a simplified email record with body text (plain or HTML as-is)
+// Email represents a simplified email record. Body is empty until the
+// message is opened and its body fetched with FetchBody.
 type Email struct {
-	From    string
+	From    Address
 	Subject string
 	Date    string
 	Body    string
+
+	// Time is the sent date, for ordering messages from different time
+	// zones; Date shows it in the sender's own
+	Time time.Time
+
+	// Sender is set when someone sent the message on behalf of From
+	Sender Address
+
+	// Recipients with their display names
+	To      []Address
+	Cc      []Address
+	ReplyTo []Address
+
+	// Message-IDs without angle brackets, used for replies
+	MessageID  string
+	InReplyTo  string
+	References []string
+
+	// IMAP flags such as \Seen, kept in sync by SetFlag
+	Flags []string
+
+	// Attachments listed from the BODYSTRUCTURE
+	Attachments []Attachment
+
+	// Messages are addressed by UID, which is only meaningful within the
+	// mailbox and UIDVALIDITY it was fetched with
+	Mailbox     string
+	UIDValidity uint32
+	UID         uint32
+	Size        uint32
+}
+
+// HasFlag reports whether the message carries the given IMAP flag
+func (e Email) HasFlag(flag string) bool {
+	for _, f := range e.Flags {
+		if strings.EqualFold(f, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Seen reports whether the message has been read
+func (e Email) Seen() bool {
+	return e.HasFlag(imap.SeenFlag)
+}
+
+// Flagged reports whether the message has been starred
+func (e Email) Flagged() bool {
+	return e.HasFlag(imap.FlaggedFlag)
+}
+
+// SetFlagLocal adds or removes a flag on the in-memory copy
+func (e *Email) SetFlagLocal(flag string, on bool) {
+	var flags []string
+	for _, f := range e.Flags {
+		if !strings.EqualFold(f, flag) {
+			flags = append(flags, f)
+		}
+	}
+	if on {
+		flags = append(flags, flag)
+	}
+	e.Flags = flags
 }
 
 func htmlToPlainText(htmlStr string) string {
@@ -73,29 +142,26 @@ func collapseBlankLines(text string) string {
 	return text
 }
 
-func FetchLatestEmails(limit uint32) ([]Email, error) {
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load configuration: %w", err)
-	}
+// FetchLatestEmails fetches the newest messages from the given mailbox
+func (s *Session) FetchLatestEmails(mailbox string, limit uint32) ([]Email, error) {
+	var emails []Email
+	err := s.Do(func(c *client.Client) error {
+		var err error
+		emails, err = s.fetchLatestEmails(c, mailbox, limit)
+		return err
+	})
+	return emails, err
+}
 
-	// Connect and login
-	c, err := client.DialTLS(fmt.Sprintf("%s:%s", cfg.EmailImapHost, cfg.EmailImapPort), nil)
+// A full load is the point where a changed UIDVALIDITY is accepted, so
+// it records the mailbox's current one
+func (s *Session) fetchLatestEmails(c *client.Client, mailbox string, limit uint32) ([]Email, error) {
+	mbox, err := c.Select(mailbox, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to %s:%s: %w", cfg.EmailImapHost, cfg.EmailImapPort, err)
+		return nil, fmt.Errorf("failed to select %s: %w", mailbox, err)
 	}
-	defer c.Logout()
+	s.setValidity(mailbox, mbox.UidValidity)
 
-	if err := c.Login(cfg.EmailUsername, cfg.EmailPassword); err != nil {
-		return nil, fmt.Errorf("login failed for %s: %w", cfg.EmailUsername, err)
-	}
-
-	// Select INBOX
-	mbox, err := c.Select("INBOX", false)
-	if err != nil {
-		return nil, fmt.Errorf("failed to select INBOX: %w", err)
-	}
 	if mbox.Messages == 0 {
 		return []Email{}, nil
 	}
@@ -106,72 +172,143 @@ func FetchLatestEmails(limit uint32) ([]Email, error) {
 	} else {
 		from = 1
 	}
-	to := mbox.Messages
 
 	seqSet := new(imap.SeqSet)
-	seqSet.AddRange(from, to)
+	seqSet.AddRange(from, mbox.Messages)
+
+	return fetchMessages(c, mbox, seqSet, false)
+}
+
+// FetchOlderEmails fetches up to limit messages with UIDs below the given
+// one, for paging back through a mailbox
+func (s *Session) FetchOlderEmails(mailbox string, before, limit uint32) ([]Email, error) {
+	if before <= 1 {
+		return []Email{}, nil
+	}
+
+	var emails []Email
+	err := s.Do(func(c *client.Client) error {
+		mbox, err := s.selectForUIDs(c, mailbox)
+		if err != nil {
+			return err
+		}
 
-	section := &imap.BodySectionName{}
-	items := []imap.FetchItem{imap.FetchEnvelope, section.FetchItem()}
+		criteria := imap.NewSearchCriteria()
+		criteria.Uid = new(imap.SeqSet)
+		criteria.Uid.AddRange(1, before-1)
+
+		uids, err := c.UidSearch(criteria)
+		if err != nil {
+			return fmt.Errorf("failed to search %s: %w", mailbox, err)
+		}
+		if len(uids) == 0 {
+			emails = []Email{}
+			return nil
+		}
+
+		// Keep the newest of the older messages
+		sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+		if uint32(len(uids)) > limit {
+			uids = uids[uint32(len(uids))-limit:]
+		}
 
-	messages := make(chan *imap.Message, limit)
+		seqSet := new(imap.SeqSet)
+		seqSet.AddNum(uids...)
+
+		emails, err = fetchMessages(c, mbox, seqSet, true)
+		return err
+	})
+	return emails, err
+}
+
+// FetchNewEmails fetches the messages that arrived after the given UID, newest first
+func (s *Session) FetchNewEmails(mailbox string, afterUID uint32) ([]Email, error) {
+	var emails []Email
+	err := s.Do(func(c *client.Client) error {
+		mbox, err := s.selectForUIDs(c, mailbox)
+		if err != nil {
+			return err
+		}
+
+		seqSet := new(imap.SeqSet)
+		seqSet.AddRange(afterUID+1, 0)
+
+		emails, err = fetchMessages(c, mbox, seqSet, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A UID range ending in * always matches the newest message, even if it
+	// is older than the requested UID
+	var fresh []Email
+	for _, e := range emails {
+		if e.UID > afterUID {
+			fresh = append(fresh, e)
+		}
+	}
+	return fresh, nil
+}
+
+// fetchMessages fetches list information for a set of sequence numbers or
+// UIDs in the selected mailbox, newest first
+func fetchMessages(c *client.Client, mbox *imap.MailboxStatus, seqSet *imap.SeqSet, uid bool) ([]Email, error) {
+	// The envelope lacks References, so fetch that one header alongside it
+	refsSection := &imap.BodySectionName{
+		BodyPartName: imap.BodyPartName{
+			Specifier: imap.HeaderSpecifier,
+			Fields:    []string{"References"},
+		},
+		Peek: true,
+	}
+
+	items := []imap.FetchItem{
+		imap.FetchEnvelope,
+		imap.FetchFlags,
+		imap.FetchUid,
+		imap.FetchRFC822Size,
+		imap.FetchBodyStructure,
+		refsSection.FetchItem(),
+	}
+
+	messages := make(chan *imap.Message, 10)
 	done := make(chan error, 1)
 
 	go func() {
-		done <- c.Fetch(seqSet, items, messages)
+		if uid {
+			done <- c.UidFetch(seqSet, items, messages)
+		} else {
+			done <- c.Fetch(seqSet, items, messages)
+		}
 	}()
 
 	var emails []Email
 	for msg := range messages {
-		fromAddr := ""
-		if len(msg.Envelope.From) > 0 {
-			fromAddr = msg.Envelope.From[0].Address()
-		}
-
-		body := ""
-
-		if r := msg.GetBody(section); r != nil {
-			mr, err := mail.CreateReader(r)
-			if err == nil {
-				var htmlBody, plainBody string
-
-				for {
-					p, err := mr.NextPart()
-					if err == io.EOF {
-						break
-					}
-					if err != nil {
-						break
-					}
-
-					switch h := p.Header.(type) {
-					case *mail.InlineHeader:
-						ct := h.Get("Content-Type")
-						b, _ := io.ReadAll(p.Body)
-						content := string(b)
-						if strings.HasPrefix(ct, "text/html") && htmlBody == "" {
-							htmlBody = content
-						} else if strings.HasPrefix(ct, "text/plain") && plainBody == "" {
-							plainBody = content
-						}
-					}
-				}
-
-				if htmlBody != "" {
-					plainText := htmlToPlainText(htmlBody)
-					cleanText := collapseBlankLines(plainText)
-					body = cleanText
-				} else if plainBody != "" {
-					body = plainBody
-				}
-			}
+		from := firstAddress(msg.Envelope.From)
+		sender := firstAddress(msg.Envelope.Sender)
+		if sender.Equal(from) {
+			sender = Address{}
 		}
 
 		emails = append(emails, Email{
-			From:    fromAddr,
-			Subject: msg.Envelope.Subject,
-			Date:    msg.Envelope.Date.Format("2006-01-02 15:04:05"),
-			Body:    body,
+			From:        from,
+			Sender:      sender,
+			Subject:     DecodeHeader(msg.Envelope.Subject),
+			Date:        msg.Envelope.Date.Format("2006-01-02 15:04:05"),
+			Time:        msg.Envelope.Date,
+			To:          addressList(msg.Envelope.To),
+			Cc:          addressList(msg.Envelope.Cc),
+			ReplyTo:     addressList(msg.Envelope.ReplyTo),
+			MessageID:   trimMsgID(msg.Envelope.MessageId),
+			InReplyTo:   trimMsgID(msg.Envelope.InReplyTo),
+			References:  parseReferences(msg.GetBody(refsSection)),
+			Flags:       msg.Flags,
+			Attachments: attachmentsOf(msg.BodyStructure),
+			Mailbox:     mbox.Name,
+			UIDValidity: mbox.UidValidity,
+			UID:         msg.Uid,
+			Size:        msg.Size,
 		})
 	}
 
@@ -179,10 +316,126 @@ func FetchLatestEmails(limit uint32) ([]Email, error) {
 		return nil, fmt.Errorf("failed to fetch messages: %w", err)
 	}
 
-	// Reverse the slice to show newest emails first
-	for i, j := 0, len(emails)-1; i < j; i, j = i+1, j-1 {
-		emails[i], emails[j] = emails[j], emails[i]
-	}
+	// Sort newest emails first; UIDs only ever grow within a mailbox
+	sort.Slice(emails, func(i, j int) bool {
+		return emails[i].UID > emails[j].UID
+	})
 
 	return emails, nil
 }
+
+// FetchBody returns the readable body of a message, downloading it only if
+// it is not already in the cache
+func (s *Session) FetchBody(mailbox string, uid uint32) (string, error) {
+	key := bodyKey(mailbox, s.uidValidity(mailbox), uid)
+	if body, ok := s.bodies.Get(key); ok {
+		return body, nil
+	}
+
+	var body string
+	err := s.Do(func(c *client.Client) error {
+		if _, err := s.selectForUIDs(c, mailbox); err != nil {
+			return err
+		}
+
+		seqSet := new(imap.SeqSet)
+		seqSet.AddNum(uid)
+
+		// Peek so that opening a message only marks it read through SetFlag
+		section := &imap.BodySectionName{Peek: true}
+		items := []imap.FetchItem{section.FetchItem()}
+
+		messages := make(chan *imap.Message, 1)
+		done := make(chan error, 1)
+
+		go func() {
+			done <- c.UidFetch(seqSet, items, messages)
+		}()
+
+		found := false
+		for msg := range messages {
+			if r := msg.GetBody(section); r != nil {
+				body = parseBody(r)
+				found = true
+			}
+		}
+
+		if err := <-done; err != nil {
+			return fmt.Errorf("failed to fetch message %d: %w", uid, err)
+		}
+		if !found {
+			return fmt.Errorf("message %d not found in %s", uid, mailbox)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// The first UID operation on a mailbox may have just learnt its UIDVALIDITY
+	s.bodies.Put(bodyKey(mailbox, s.uidValidity(mailbox), uid), body)
+	return body, nil
+}
+
+// trimMsgID strips whitespace and angle brackets from a Message-ID
+func trimMsgID(id string) string {
+	return strings.Trim(strings.TrimSpace(id), "<>")
+}
+
+// parseReferences reads the Message-IDs out of a fetched References header
+func parseReferences(r io.Reader) []string {
+	if r == nil {
+		return nil
+	}
+
+	th, err := textproto.ReadHeader(bufio.NewReader(r))
+	if err != nil {
+		return nil
+	}
+
+	h := mail.Header{Header: message.Header{Header: th}}
+	refs, err := h.MsgIDList("References")
+	if err != nil {
+		return nil
+	}
+	return refs
+}
+
+// parseBody extracts readable text from a raw message, preferring the HTML
+// part converted to plain text and falling back to the plain text part.
+// Transfer encodings and charsets are decoded to UTF-8.
+func parseBody(r io.Reader) string {
+	mr, err := mail.CreateReader(r)
+	if parseErr(err) {
+		return ""
+	}
+
+	var htmlBody, plainBody string
+
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if parseErr(err) {
+			break
+		}
+
+		switch h := p.Header.(type) {
+		case *mail.InlineHeader:
+			ct, _, _ := h.ContentType()
+			b, _ := io.ReadAll(p.Body)
+			if ct == "text/html" && htmlBody == "" {
+				htmlBody = decodeText(b, ct)
+			} else if (ct == "text/plain" || ct == "") && plainBody == "" {
+				plainBody = decodeText(b, ct)
+			}
+		}
+	}
+
+	if htmlBody != "" {
+		plainText := htmlToPlainText(htmlBody)
+		return collapseBlankLines(plainText)
+	}
+	return plainBody
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/commands"
+)
+
+// ErrOtherDeleted is returned when messages cannot be expunged on their own
+// because the server lacks UID EXPUNGE and a plain EXPUNGE would also remove
+// other messages that are marked deleted
+var ErrOtherDeleted = errors.New("other messages in the mailbox are marked deleted")
+
+// uidExpungeCommand is a UID EXPUNGE, as defined in RFC 4315 section 2.1
+type uidExpungeCommand struct {
+	seqSet *imap.SeqSet
+}
+
+func (cmd *uidExpungeCommand) Command() *imap.Command {
+	return &imap.Command{
+		Name:      "EXPUNGE",
+		Arguments: []interface{}{cmd.seqSet},
+	}
+}
+
+// deleteUIDs flags messages in the selected mailbox as deleted and expunges
+// only those. Servers without UIDPLUS can only expunge every deleted message
+// at once, so that is done only when no others are marked deleted.
+func deleteUIDs(c *client.Client, uids []uint32) error {
+	if len(uids) == 0 {
+		return nil
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if err := c.UidStore(seqSet, item, []interface{}{imap.DeletedFlag}, nil); err != nil {
+		return fmt.Errorf("failed to flag messages as deleted: %w", err)
+	}
+
+	uidplus, err := c.Support("UIDPLUS")
+	if err != nil {
+		return fmt.Errorf("failed to check server capabilities: %w", err)
+	}
+	if uidplus {
+		status, err := c.Execute(&commands.Uid{Cmd: &uidExpungeCommand{seqSet: seqSet}}, nil)
+		if err == nil {
+			err = status.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to expunge messages: %w", err)
+		}
+		return nil
+	}
+
+	others := imap.NewSearchCriteria()
+	others.WithFlags = []string{imap.DeletedFlag}
+	others.Not = []*imap.SearchCriteria{{Uid: seqSet}}
+	found, err := c.UidSearch(others)
+	if err != nil {
+		return fmt.Errorf("failed to search for deleted messages: %w", err)
+	}
+	if len(found) > 0 {
+		return fmt.Errorf("%w (%d) and the server cannot expunge messages one by one, so the selected ones were only marked deleted",
+			ErrOtherDeleted, len(found))
+	}
+
+	if err := c.Expunge(nil); err != nil {
+		return fmt.Errorf("failed to expunge messages: %w", err)
+	}
+	return nil
+}
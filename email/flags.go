@@ -0,0 +1,72 @@
+package email
+
+import (
+	"fmt"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// SetFlag adds or removes an IMAP flag on messages in a mailbox
+func (s *Session) SetFlag(mailbox string, uids []uint32, flag string, on bool) error {
+	if len(uids) == 0 {
+		return nil
+	}
+
+	return s.Do(func(c *client.Client) error {
+		if _, err := s.selectForUIDs(c, mailbox); err != nil {
+			return err
+		}
+
+		seqSet := new(imap.SeqSet)
+		seqSet.AddNum(uids...)
+
+		var op imap.FlagsOp = imap.RemoveFlags
+		if on {
+			op = imap.AddFlags
+		}
+
+		item := imap.FormatFlagsOp(op, true)
+		if err := c.UidStore(seqSet, item, []interface{}{flag}, nil); err != nil {
+			return fmt.Errorf("failed to update %s flag: %w", flag, err)
+		}
+		return nil
+	})
+}
+
+// FetchFlags returns the current flags of the given messages, keyed by UID,
+// so changes made by other clients can be picked up. Messages missing from
+// the result no longer exist on the server.
+func (s *Session) FetchFlags(mailbox string, uids []uint32) (map[uint32][]string, error) {
+	flags := make(map[uint32][]string, len(uids))
+	if len(uids) == 0 {
+		return flags, nil
+	}
+
+	err := s.Do(func(c *client.Client) error {
+		if _, err := s.selectForUIDs(c, mailbox); err != nil {
+			return err
+		}
+
+		seqSet := new(imap.SeqSet)
+		seqSet.AddNum(uids...)
+
+		items := []imap.FetchItem{imap.FetchUid, imap.FetchFlags}
+		messages := make(chan *imap.Message, 10)
+		done := make(chan error, 1)
+
+		go func() {
+			done <- c.UidFetch(seqSet, items, messages)
+		}()
+
+		for msg := range messages {
+			flags[msg.Uid] = msg.Flags
+		}
+
+		if err := <-done; err != nil {
+			return fmt.Errorf("failed to fetch flags: %w", err)
+		}
+		return nil
+	})
+	return flags, err
+}
@@ -0,0 +1,153 @@
+package email
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Zachkp/GoMail/config"
+	"github.com/emersion/go-imap/client"
+)
+
+const (
+	// How often to poll with NOOP on servers without IDLE support
+	watchPollInterval = time.Minute
+	// How long to wait before reconnecting a dropped watch connection
+	watchRetryDelay = 30 * time.Second
+)
+
+// MailEvent reports that new messages arrived in a watched mailbox
+type MailEvent struct {
+	Mailbox  string
+	Messages uint32
+}
+
+// Watcher keeps a dedicated connection idling on one mailbox so new mail is
+// noticed as soon as the server announces it. Servers without IDLE are
+// polled with NOOP instead.
+type Watcher struct {
+	cfg     *config.Config
+	mailbox string
+	events  chan MailEvent
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// Watch starts watching a mailbox for new mail, replacing any mailbox the
+// session was previously watching
+func (s *Session) Watch(mailbox string) *Watcher {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	if s.watcher != nil {
+		s.watcher.Stop()
+	}
+
+	w := &Watcher{
+		cfg:     s.cfg,
+		mailbox: mailbox,
+		events:  make(chan MailEvent, 1),
+		stop:    make(chan struct{}),
+	}
+	s.watcher = w
+
+	go w.run()
+
+	return w
+}
+
+// Events returns the channel new mail events are delivered on. It is closed
+// once the watcher stops.
+func (w *Watcher) Events() <-chan MailEvent {
+	return w.events
+}
+
+// Stop ends the watch and closes its connection
+func (w *Watcher) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+}
+
+// run keeps a watch connection alive until the watcher is stopped
+func (w *Watcher) run() {
+	defer close(w.events)
+
+	for {
+		// Errors are not fatal here, the connection is simply retried
+		w.watch()
+
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(watchRetryDelay):
+		}
+	}
+}
+
+// watch idles on the mailbox until the connection drops or the watcher stops
+func (w *Watcher) watch() error {
+	// IDLE waits indefinitely by design, so commands here have no timeout
+	c, err := dial(w.cfg, 0)
+	if err != nil {
+		return err
+	}
+	defer c.Logout()
+
+	updates := make(chan client.Update, 10)
+	c.Updates = updates
+
+	mbox, err := c.Select(w.mailbox, true)
+	if err != nil {
+		return fmt.Errorf("failed to select %s: %w", w.mailbox, err)
+	}
+	last := mbox.Messages
+
+	idleStop := make(chan struct{})
+	idleDone := make(chan error, 1)
+	go func() {
+		idleDone <- c.Idle(idleStop, &client.IdleOptions{PollInterval: watchPollInterval})
+	}()
+
+	stopping := false
+	for {
+		select {
+		case update := <-updates:
+			switch u := update.(type) {
+			case *client.MailboxUpdate:
+				if u.Mailbox.Messages > last {
+					w.notify(MailEvent{Mailbox: w.mailbox, Messages: u.Mailbox.Messages})
+				}
+				last = u.Mailbox.Messages
+			case *client.ExpungeUpdate:
+				if last > 0 {
+					last--
+				}
+			}
+
+		case err := <-idleDone:
+			if stopping {
+				return nil
+			}
+			if err == nil {
+				err = fmt.Errorf("idle on %s ended unexpectedly", w.mailbox)
+			}
+			return err
+
+		case <-w.stop:
+			if !stopping {
+				stopping = true
+				close(idleStop)
+			}
+		}
+	}
+}
+
+// notify delivers an event without blocking; a pending event already tells
+// the listener to look for new mail
+func (w *Watcher) notify(event MailEvent) {
+	select {
+	case w.events <- event:
+	default:
+	}
+}
@@ -0,0 +1,199 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// DefaultMailbox is the mailbox opened when the TUI starts
+const DefaultMailbox = "INBOX"
+
+// ErrNoSpecialUse is returned when no mailbox carries a special-use attribute
+var ErrNoSpecialUse = errors.New("server has no mailbox marked")
+
+// ErrUIDValidityChanged is returned when a mailbox's UIDs were reset on the
+// server since it was loaded
+var ErrUIDValidityChanged = errors.New("mailbox was reset on the server")
+
+// Names that special mailboxes commonly have on servers without SPECIAL-USE,
+// tried when no mailbox carries the attribute
+var specialUseNames = map[string][]string{
+	imap.TrashAttr: {"Trash", "Deleted Items", "Deleted Messages"},
+}
+
+// Mailbox is a folder on the IMAP server along with its message counts
+type Mailbox struct {
+	Name       string
+	Delimiter  string
+	Attributes []string
+	Messages   uint32
+	Unseen     uint32
+}
+
+// HasAttr reports whether the mailbox was listed with the given attribute
+func (m Mailbox) HasAttr(attr string) bool {
+	for _, a := range m.Attributes {
+		if strings.EqualFold(a, attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// leaf returns the last part of the mailbox's name, e.g. Trash for INBOX.Trash
+func (m Mailbox) leaf() string {
+	if m.Delimiter == "" {
+		return m.Name
+	}
+	return m.Name[strings.LastIndex(m.Name, m.Delimiter)+len(m.Delimiter):]
+}
+
+// ListMailboxes returns every selectable mailbox with its message and unread counts
+func (s *Session) ListMailboxes() ([]Mailbox, error) {
+	var mailboxes []Mailbox
+	err := s.Do(func(c *client.Client) error {
+		var err error
+		mailboxes, err = listMailboxes(c)
+		if err != nil {
+			return err
+		}
+		fillCounts(c, mailboxes)
+		return nil
+	})
+	return mailboxes, err
+}
+
+func listMailboxes(c *client.Client) ([]Mailbox, error) {
+	infos := make(chan *imap.MailboxInfo, 10)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.List("", "*", infos)
+	}()
+
+	var mailboxes []Mailbox
+	for info := range infos {
+		mb := Mailbox{
+			Name:       info.Name,
+			Delimiter:  info.Delimiter,
+			Attributes: info.Attributes,
+		}
+		if mb.HasAttr(imap.NoSelectAttr) {
+			continue
+		}
+		mailboxes = append(mailboxes, mb)
+	}
+
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("failed to list mailboxes: %w", err)
+	}
+
+	sortMailboxes(mailboxes)
+
+	return mailboxes, nil
+}
+
+// fillCounts fetches message and unread counts for each mailbox
+func fillCounts(c *client.Client, mailboxes []Mailbox) {
+	items := []imap.StatusItem{imap.StatusMessages, imap.StatusUnseen}
+	for i := range mailboxes {
+		status, err := c.Status(mailboxes[i].Name, items)
+		if err != nil {
+			// Some servers refuse STATUS on special folders; keep the mailbox without counts
+			continue
+		}
+		mailboxes[i].Messages = status.Messages
+		mailboxes[i].Unseen = status.Unseen
+	}
+}
+
+// sortMailboxes keeps INBOX first and orders the rest by name
+func sortMailboxes(mailboxes []Mailbox) {
+	sort.SliceStable(mailboxes, func(i, j int) bool {
+		iInbox := strings.EqualFold(mailboxes[i].Name, DefaultMailbox)
+		jInbox := strings.EqualFold(mailboxes[j].Name, DefaultMailbox)
+		if iInbox != jInbox {
+			return iInbox
+		}
+		return strings.ToLower(mailboxes[i].Name) < strings.ToLower(mailboxes[j].Name)
+	})
+}
+
+// SpecialUseMailbox returns the mailbox the server marks with a special-use
+// attribute such as \Sent or \Drafts. When several attributes are given the
+// first one found wins. If none is marked, a mailbox with a usual name for
+// the attribute is used instead. Results are cached for the session.
+func (s *Session) SpecialUseMailbox(attrs ...string) (string, error) {
+	var name string
+	err := s.Do(func(c *client.Client) error {
+		for _, attr := range attrs {
+			if cached, ok := s.specialUse[attr]; ok {
+				name = cached
+				return nil
+			}
+		}
+
+		mailboxes, err := listMailboxes(c)
+		if err != nil {
+			return err
+		}
+
+		for _, attr := range attrs {
+			for _, mb := range mailboxes {
+				if mb.HasAttr(attr) {
+					name = mb.Name
+					s.specialUse[attr] = name
+					return nil
+				}
+			}
+		}
+		for _, attr := range attrs {
+			for _, usual := range specialUseNames[attr] {
+				for _, mb := range mailboxes {
+					if strings.EqualFold(mb.leaf(), usual) {
+						name = mb.Name
+						s.specialUse[attr] = name
+						return nil
+					}
+				}
+			}
+		}
+		return fmt.Errorf("%w %s", ErrNoSpecialUse, strings.Join(attrs, " or "))
+	})
+	return name, err
+}
+
+// configuredMailbox returns the mailbox set in the config, or the one found
+// through the given special-use attributes
+func (s *Session) configuredMailbox(configured, envVar string, attrs ...string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+
+	name, err := s.SpecialUseMailbox(attrs...)
+	if err != nil {
+		return "", fmt.Errorf("%w; set %s in your config", err, envVar)
+	}
+	return name, nil
+}
+
+// AppendMessage stores a raw message in a mailbox with the given flags
+func (s *Session) AppendMessage(mailbox string, flags []string, raw []byte) error {
+	return s.Do(func(c *client.Client) error {
+		return appendMessage(c, mailbox, flags, raw)
+	})
+}
+
+func appendMessage(c *client.Client, mailbox string, flags []string, raw []byte) error {
+	if err := c.Append(mailbox, flags, time.Now(), bytes.NewBuffer(raw)); err != nil {
+		return fmt.Errorf("failed to append to %s: %w", mailbox, err)
+	}
+	return nil
+}
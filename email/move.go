@@ -0,0 +1,100 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// TrashMailbox returns the configured Trash mailbox or discovers it through
+// the \Trash special-use attribute or a name such as Trash or Deleted Items
+func (s *Session) TrashMailbox() (string, error) {
+	return s.configuredMailbox(s.cfg.TrashMailbox, "EMAIL_TRASH_MAILBOX", imap.TrashAttr)
+}
+
+// ArchiveMailbox returns the configured archive mailbox or discovers it
+// through the \Archive or, as on Gmail, the \All special-use attribute
+func (s *Session) ArchiveMailbox() (string, error) {
+	return s.configuredMailbox(s.cfg.ArchiveMailbox, "EMAIL_ARCHIVE_MAILBOX", imap.ArchiveAttr, imap.AllAttr)
+}
+
+// Move moves messages to another mailbox. MOVE is used when the server
+// advertises it, otherwise COPY followed by STORE \Deleted and an EXPUNGE
+// of just those messages.
+func (s *Session) Move(mailbox string, uids []uint32, dest string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	if mailbox == dest {
+		return fmt.Errorf("messages are already in %s", dest)
+	}
+
+	return s.Do(func(c *client.Client) error {
+		if _, err := s.selectForUIDs(c, mailbox); err != nil {
+			return err
+		}
+
+		seqSet := new(imap.SeqSet)
+		seqSet.AddNum(uids...)
+
+		move, err := c.Support("MOVE")
+		if err != nil {
+			return fmt.Errorf("failed to check server capabilities: %w", err)
+		}
+		if move {
+			if err := c.UidMove(seqSet, dest); err != nil {
+				return fmt.Errorf("failed to move messages to %s: %w", dest, err)
+			}
+			return nil
+		}
+
+		if err := c.UidCopy(seqSet, dest); err != nil {
+			return fmt.Errorf("failed to copy messages to %s: %w", dest, err)
+		}
+		return deleteUIDs(c, uids)
+	})
+}
+
+// DeletesForGood reports whether Delete removes messages from the mailbox
+// for good, because there is no Trash mailbox or they are already in it
+func (s *Session) DeletesForGood(mailbox string) (bool, error) {
+	trash, err := s.TrashMailbox()
+	if errors.Is(err, ErrNoSpecialUse) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return trash == mailbox, nil
+}
+
+// Delete moves messages to the Trash, or removes them for good when there
+// is no Trash mailbox or they are already in it. Check DeletesForGood first
+// to ask before anything is lost.
+func (s *Session) Delete(mailbox string, uids []uint32) error {
+	trash, err := s.TrashMailbox()
+	if err != nil && !errors.Is(err, ErrNoSpecialUse) {
+		return err
+	}
+	if err == nil && trash != mailbox {
+		return s.Move(mailbox, uids, trash)
+	}
+
+	return s.Do(func(c *client.Client) error {
+		if _, err := s.selectForUIDs(c, mailbox); err != nil {
+			return err
+		}
+		return deleteUIDs(c, uids)
+	})
+}
+
+// Archive moves messages to the archive mailbox
+func (s *Session) Archive(mailbox string, uids []uint32) error {
+	archive, err := s.ArchiveMailbox()
+	if err != nil {
+		return err
+	}
+	return s.Move(mailbox, uids, archive)
+}
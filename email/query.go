@@ -0,0 +1,521 @@
+package email
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/emersion/go-imap"
+)
+
+// Query is a parsed search query such as
+//
+//	from:alice subject:"deploy" is:unread has:attachment after:2026-01-01 -label:spam
+//
+// Terms are joined with AND unless separated by OR; a leading - negates a
+// term, and parentheses group terms. Words without a field are looked for
+// in the sender, subject and body.
+type Query struct {
+	root queryNode
+}
+
+// QueryError is a syntax error in a search query, at a 1-based column
+type QueryError struct {
+	Col int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Col, e.Msg)
+}
+
+// TextMatcher reports whether a word of a query matches a piece of message
+// text, letting callers choose how loosely words match
+type TextMatcher func(pattern, text string) bool
+
+// Term is a word of a query that is looked for in message text. Field is
+// empty for words looked for in the sender, subject and body.
+type Term struct {
+	Field string
+	Value string
+}
+
+// queryNode is a node of the parsed query
+type queryNode interface {
+	match(e Email, text TextMatcher) bool
+	criteria() *imap.SearchCriteria
+}
+
+// Fields that words without one of their own can be limited to
+var textFields = []string{"from", "to", "cc", "subject", "body"}
+
+// ParseQuery parses a search query. An empty query matches every message.
+func ParseQuery(s string) (*Query, error) {
+	return ParseQueryIn(s, "")
+}
+
+// ParseQueryIn parses a search query whose words without a field of their
+// own are only looked for in field, such as "subject". An empty field
+// looks for them in the sender, subject and body.
+func ParseQueryIn(s, field string) (*Query, error) {
+	field = strings.ToLower(field)
+	if field != "" && !slices.Contains(textFields, field) {
+		return nil, fmt.Errorf("unknown search field %q, try %s", field, strings.Join(textFields, ", "))
+	}
+
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens, field: field}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, &QueryError{Col: tok.col, Msg: "unexpected )"}
+	}
+	return &Query{root: root}, nil
+}
+
+// Match reports whether a loaded message matches the query. Words are
+// matched with text, or as case-insensitive substrings, the way IMAP
+// SEARCH matches them, when text is nil.
+func (q *Query) Match(e Email, text TextMatcher) bool {
+	if text == nil {
+		text = containsFold
+	}
+	return q.root.match(e, text)
+}
+
+// Terms lists the words of the query that messages are ranked and
+// highlighted by, leaving out negated ones. A nil query has none.
+func (q *Query) Terms() []Term {
+	if q == nil {
+		return nil
+	}
+	var terms []Term
+	var walk func(n queryNode)
+	walk = func(n queryNode) {
+		switch n := n.(type) {
+		case andNode:
+			for _, term := range n {
+				walk(term)
+			}
+		case orNode:
+			walk(n[0])
+			walk(n[1])
+		case fieldNode:
+			switch n.field {
+			case "", "from", "to", "cc", "subject", "body":
+				terms = append(terms, Term{Field: n.field, Value: n.value})
+			}
+		}
+	}
+	walk(q.root)
+	return terms
+}
+
+// Criteria converts the query to IMAP SEARCH criteria
+func (q *Query) Criteria() *imap.SearchCriteria {
+	return q.root.criteria()
+}
+
+// Token kinds of the query lexer
+const (
+	tokWord = iota
+	tokNot
+	tokOpen
+	tokClose
+	tokOr
+)
+
+type queryToken struct {
+	kind   int
+	field  string
+	value  string
+	quoted bool
+	col    int
+}
+
+// lexQuery splits a query into words, field:value pairs, negations and
+// parentheses. Double quotes keep spaces inside a value.
+func lexQuery(s string) ([]queryToken, error) {
+	runes := []rune(s)
+	var tokens []queryToken
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokOpen, col: i + 1})
+			i++
+			continue
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokClose, col: i + 1})
+			i++
+			continue
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, queryToken{kind: tokNot, col: i + 1})
+			i++
+			continue
+		}
+
+		// A word runs to the next space or parenthesis outside quotes
+		start := i
+		var b strings.Builder
+		field := ""
+		quoted := false
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' {
+			switch {
+			case runes[i] == '"':
+				end := i + 1
+				for end < len(runes) && runes[end] != '"' {
+					end++
+				}
+				if end == len(runes) {
+					return nil, &QueryError{Col: i + 1, Msg: "missing closing quote"}
+				}
+				b.WriteString(string(runes[i+1 : end]))
+				quoted = true
+				i = end + 1
+			case runes[i] == ':' && field == "" && !quoted && isFieldName(b.String()) &&
+				!(i+1 < len(runes) && runes[i+1] == '/'):
+				field = strings.ToLower(b.String())
+				b.Reset()
+				i++
+			default:
+				b.WriteRune(runes[i])
+				i++
+			}
+		}
+
+		tok := queryToken{kind: tokWord, field: field, value: b.String(), quoted: quoted, col: start + 1}
+		if field == "" && !quoted && tok.value == "OR" {
+			tok.kind = tokOr
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// isFieldName reports whether a word before a colon names a field, so
+// things like times and URLs are still searched as text
+func isFieldName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// queryParser is a recursive descent parser over the lexed tokens. Words
+// without a field get the parser's field.
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	field  string
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// parseOr reads terms joined by OR
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokOr {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if and, ok := right.(andNode); ok && len(and) == 0 {
+			return nil, &QueryError{Col: tok.col, Msg: "OR needs a term on both sides"}
+		}
+		if and, ok := left.(andNode); ok && len(and) == 0 {
+			return nil, &QueryError{Col: tok.col, Msg: "OR needs a term on both sides"}
+		}
+		left = orNode{left, right}
+	}
+}
+
+// parseAnd reads terms up to the end of the query, group or an OR
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var terms andNode
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind == tokClose || tok.kind == tokOr {
+			break
+		}
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+// parseTerm reads a negated term, a group or a single word
+func (p *queryParser) parseTerm() (queryNode, error) {
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.kind {
+	case tokNot:
+		next, ok := p.peek()
+		if !ok || next.kind == tokClose || next.kind == tokOr {
+			return nil, &QueryError{Col: tok.col, Msg: "nothing to negate after -"}
+		}
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{term}, nil
+
+	case tokOpen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing, ok := p.peek()
+		if !ok || closing.kind != tokClose {
+			return nil, &QueryError{Col: tok.col, Msg: "missing closing )"}
+		}
+		p.pos++
+		if and, ok := inner.(andNode); ok && len(and) == 0 {
+			return nil, &QueryError{Col: tok.col, Msg: "empty group"}
+		}
+		return inner, nil
+	}
+
+	if tok.field == "" {
+		tok.field = p.field
+	}
+	return newFieldNode(tok)
+}
+
+// andNode matches when every term matches; with no terms it matches all
+type andNode []queryNode
+
+func (n andNode) match(e Email, text TextMatcher) bool {
+	for _, term := range n {
+		if !term.match(e, text) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n andNode) criteria() *imap.SearchCriteria {
+	c := imap.NewSearchCriteria()
+	for _, term := range n {
+		mergeCriteria(c, term.criteria())
+	}
+	return c
+}
+
+// orNode matches when either side matches
+type orNode [2]queryNode
+
+func (n orNode) match(e Email, text TextMatcher) bool {
+	return n[0].match(e, text) || n[1].match(e, text)
+}
+
+func (n orNode) criteria() *imap.SearchCriteria {
+	c := imap.NewSearchCriteria()
+	c.Or = [][2]*imap.SearchCriteria{{n[0].criteria(), n[1].criteria()}}
+	return c
+}
+
+// notNode matches when its term does not
+type notNode struct {
+	term queryNode
+}
+
+func (n notNode) match(e Email, text TextMatcher) bool {
+	return !n.term.match(e, text)
+}
+
+func (n notNode) criteria() *imap.SearchCriteria {
+	c := imap.NewSearchCriteria()
+	c.Not = []*imap.SearchCriteria{n.term.criteria()}
+	return c
+}
+
+// fieldNode is a single word, optionally restricted to a field
+type fieldNode struct {
+	field string
+	value string
+	date  time.Time
+}
+
+// newFieldNode checks a word's field and value
+func newFieldNode(tok queryToken) (queryNode, error) {
+	n := fieldNode{field: tok.field, value: tok.value}
+	if n.value == "" {
+		return nil, &QueryError{Col: tok.col, Msg: fmt.Sprintf("%s: needs a value", tok.field)}
+	}
+
+	switch n.field {
+	case "", "from", "to", "cc", "subject", "body", "label":
+	case "is":
+		n.value = strings.ToLower(n.value)
+		switch n.value {
+		case "unread", "read", "seen", "flagged", "starred", "unflagged", "answered", "replied", "draft":
+		default:
+			return nil, &QueryError{Col: tok.col, Msg: fmt.Sprintf("unknown is:%s, try unread, read, flagged, answered or draft", tok.value)}
+		}
+	case "has":
+		n.value = strings.ToLower(n.value)
+		if n.value != "attachment" && n.value != "attachments" {
+			return nil, &QueryError{Col: tok.col, Msg: fmt.Sprintf("unknown has:%s, try has:attachment", tok.value)}
+		}
+	case "after", "since", "before":
+		t, err := parseSearchDate(n.value)
+		if err != nil {
+			return nil, &QueryError{Col: tok.col, Msg: err.Error()}
+		}
+		n.date = t
+		if n.field == "since" {
+			n.field = "after"
+		}
+	default:
+		return nil, &QueryError{Col: tok.col, Msg: fmt.Sprintf("unknown field %s:", tok.field)}
+	}
+	return n, nil
+}
+
+func (n fieldNode) match(e Email, text TextMatcher) bool {
+	switch n.field {
+	case "from":
+		return text(n.value, e.From.String()) || (e.Sender.Addr != "" && text(n.value, e.Sender.String()))
+	case "to":
+		return text(n.value, FormatAddresses(e.To))
+	case "cc":
+		return text(n.value, FormatAddresses(e.Cc))
+	case "subject":
+		return text(n.value, e.Subject)
+	case "body":
+		return text(n.value, e.Body)
+	case "label":
+		for _, f := range e.Flags {
+			if strings.EqualFold(f, n.value) {
+				return true
+			}
+		}
+		return false
+	case "is":
+		switch n.value {
+		case "unread":
+			return !e.Seen()
+		case "read", "seen":
+			return e.Seen()
+		case "flagged", "starred":
+			return e.Flagged()
+		case "unflagged":
+			return !e.Flagged()
+		case "answered", "replied":
+			return e.HasFlag(imap.AnsweredFlag)
+		case "draft":
+			return e.HasFlag(imap.DraftFlag)
+		}
+		return false
+	case "has":
+		return len(e.Attachments) > 0
+	case "after":
+		return len(e.Date) >= 10 && e.Date[:10] >= n.date.Format("2006-01-02")
+	case "before":
+		return len(e.Date) >= 10 && e.Date[:10] < n.date.Format("2006-01-02")
+	}
+
+	return text(n.value, e.From.String()) || text(n.value, e.Subject) || text(n.value, e.Body)
+}
+
+func (n fieldNode) criteria() *imap.SearchCriteria {
+	c := imap.NewSearchCriteria()
+	switch n.field {
+	case "from", "to", "cc", "subject":
+		c.Header.Add(n.field, n.value)
+	case "body":
+		c.Body = []string{n.value}
+	case "label":
+		c.WithFlags = []string{n.value}
+	case "is":
+		switch n.value {
+		case "unread":
+			c.WithoutFlags = []string{imap.SeenFlag}
+		case "read", "seen":
+			c.WithFlags = []string{imap.SeenFlag}
+		case "flagged", "starred":
+			c.WithFlags = []string{imap.FlaggedFlag}
+		case "unflagged":
+			c.WithoutFlags = []string{imap.FlaggedFlag}
+		case "answered", "replied":
+			c.WithFlags = []string{imap.AnsweredFlag}
+		case "draft":
+			c.WithFlags = []string{imap.DraftFlag}
+		}
+	case "has":
+		// IMAP cannot search for attachments; mixed multipart messages are
+		// the ones that carry them
+		c.Header.Add("Content-Type", "multipart/mixed")
+	case "after":
+		c.Since = n.date
+	case "before":
+		c.Before = n.date
+	default:
+		c.Text = []string{n.value}
+	}
+	return c
+}
+
+// mergeCriteria adds the conditions of src to dst, so both must match
+func mergeCriteria(dst, src *imap.SearchCriteria) {
+	for key, values := range src.Header {
+		for _, v := range values {
+			dst.Header.Add(key, v)
+		}
+	}
+	dst.Body = append(dst.Body, src.Body...)
+	dst.Text = append(dst.Text, src.Text...)
+	dst.WithFlags = append(dst.WithFlags, src.WithFlags...)
+	dst.WithoutFlags = append(dst.WithoutFlags, src.WithoutFlags...)
+	dst.Not = append(dst.Not, src.Not...)
+	dst.Or = append(dst.Or, src.Or...)
+	if src.Since.After(dst.Since) {
+		dst.Since = src.Since
+	}
+	if !src.Before.IsZero() && (dst.Before.IsZero() || src.Before.Before(dst.Before)) {
+		dst.Before = src.Before
+	}
+}
+
+// containsFold reports whether text contains pattern, ignoring case
+func containsFold(pattern, text string) bool {
+	return strings.Contains(strings.ToLower(text), strings.ToLower(pattern))
+}
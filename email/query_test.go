@@ -0,0 +1,213 @@
+package email
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+// queryEmails are the messages the match table runs against
+var queryEmails = []Email{
+	{
+		UID:         1,
+		From:        Address{Name: "Alice", Addr: "alice@example.com"},
+		Subject:     "Weekly sync notes",
+		Body:        "Agenda attached",
+		Date:        "2026-02-10 09:00:00",
+		Flags:       []string{imap.SeenFlag},
+		Attachments: []Attachment{{Part: []int{2}, Filename: "agenda.pdf"}},
+	},
+	{
+		UID:     2,
+		From:    Address{Name: "Bob", Addr: "bob@example.com"},
+		Subject: "Deploy failed",
+		Body:    "Staging is down, call at 10:30",
+		Date:    "2026-01-15 18:30:00",
+		Flags:   []string{imap.FlaggedFlag},
+	},
+	{
+		UID:     3,
+		From:    Address{Name: "Carol", Addr: "carol@example.com"},
+		Subject: "Re: Weekly sync notes",
+		Body:    "Sounds good",
+		Date:    "2025-12-31 23:59:00",
+		To:      []Address{{Name: "Alice", Addr: "alice@example.com"}},
+		Cc:      []Address{{Name: "Bob", Addr: "bob@example.com"}},
+		Flags:   []string{imap.SeenFlag, imap.AnsweredFlag, "work"},
+	},
+	{
+		UID:     4,
+		From:    Address{Addr: "me@example.com"},
+		Subject: "Plans",
+		Body:    "See https://example.com/plans",
+		Date:    "2026-03-01 08:00:00",
+		Flags:   []string{imap.DraftFlag},
+	},
+}
+
+func TestQueryMatch(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []uint32
+	}{
+		{query: "", want: []uint32{1, 2, 3, 4}},
+		{query: "alice", want: []uint32{1}},
+		{query: "ALICE", want: []uint32{1}},
+		{query: "to:alice", want: []uint32{3}},
+		{query: "cc:bob", want: []uint32{3}},
+		{query: "from:alice OR from:bob", want: []uint32{1, 2}},
+		{query: `subject:"sync notes"`, want: []uint32{1, 3}},
+		{query: `"is down"`, want: []uint32{2}},
+		{query: "body:agenda", want: []uint32{1}},
+		{query: "weekly -from:carol", want: []uint32{1}},
+		{query: "-(weekly OR deploy)", want: []uint32{4}},
+		{query: "weekly alice OR deploy", want: []uint32{1, 2}},
+		{query: "weekly (alice OR deploy)", want: []uint32{1}},
+		{query: "is:unread", want: []uint32{2, 4}},
+		{query: "IS:UNREAD", want: []uint32{2, 4}},
+		{query: "is:read", want: []uint32{1, 3}},
+		{query: "is:flagged", want: []uint32{2}},
+		{query: "is:unflagged", want: []uint32{1, 3, 4}},
+		{query: "is:answered", want: []uint32{3}},
+		{query: "is:draft", want: []uint32{4}},
+		{query: "has:attachment", want: []uint32{1}},
+		{query: "label:work", want: []uint32{3}},
+		{query: "after:2026-01-15", want: []uint32{1, 2, 4}},
+		{query: "since:2026/02/01", want: []uint32{1, 4}},
+		{query: "before:2026-01-15", want: []uint32{3}},
+		{query: "after:2026-01-01 before:2026-03-01", want: []uint32{1, 2}},
+		{query: "10:30", want: []uint32{2}},
+		{query: "https://example.com", want: []uint32{4}},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", tt.query, err)
+			continue
+		}
+		var got []uint32
+		for _, e := range queryEmails {
+			if q.Match(e, nil) {
+				got = append(got, e.UID)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParseQuery(%q) matched %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+// criteria builds the search criteria a test expects
+func criteria(fill func(c *imap.SearchCriteria)) *imap.SearchCriteria {
+	c := imap.NewSearchCriteria()
+	fill(c)
+	return c
+}
+
+func TestQueryCriteria(t *testing.T) {
+	text := func(words ...string) *imap.SearchCriteria {
+		return criteria(func(c *imap.SearchCriteria) { c.Text = words })
+	}
+
+	tests := []struct {
+		query string
+		want  *imap.SearchCriteria
+	}{
+		{query: "", want: imap.NewSearchCriteria()},
+		{query: "deploy", want: text("deploy")},
+		{query: "deploy failed", want: text("deploy", "failed")},
+		{query: `"deploy failed"`, want: text("deploy failed")},
+		{query: "from:alice", want: criteria(func(c *imap.SearchCriteria) { c.Header.Add("From", "alice") })},
+		{query: `subject:"weekly sync" to:bob`, want: criteria(func(c *imap.SearchCriteria) {
+			c.Header.Add("Subject", "weekly sync")
+			c.Header.Add("To", "bob")
+		})},
+		{query: "body:agenda", want: criteria(func(c *imap.SearchCriteria) { c.Body = []string{"agenda"} })},
+		{query: "is:unread", want: criteria(func(c *imap.SearchCriteria) { c.WithoutFlags = []string{imap.SeenFlag} })},
+		{query: "is:flagged label:work", want: criteria(func(c *imap.SearchCriteria) {
+			c.WithFlags = []string{imap.FlaggedFlag, "work"}
+		})},
+		{query: "is:replied is:draft", want: criteria(func(c *imap.SearchCriteria) {
+			c.WithFlags = []string{imap.AnsweredFlag, imap.DraftFlag}
+		})},
+		{query: "has:attachment", want: criteria(func(c *imap.SearchCriteria) {
+			c.Header.Add("Content-Type", "multipart/mixed")
+		})},
+		{query: "after:2026-01-31 before:2026/02/28", want: criteria(func(c *imap.SearchCriteria) {
+			c.Since = time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+			c.Before = time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC)
+		})},
+		{query: "since:2026-01-01 after:2026-02-01 before:2026-05-01 before:2026-04-01", want: criteria(func(c *imap.SearchCriteria) {
+			c.Since = time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+			c.Before = time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+		})},
+		{query: "-from:bob", want: criteria(func(c *imap.SearchCriteria) {
+			c.Not = []*imap.SearchCriteria{criteria(func(c *imap.SearchCriteria) { c.Header.Add("From", "bob") })}
+		})},
+		{query: "a OR b", want: criteria(func(c *imap.SearchCriteria) {
+			c.Or = [][2]*imap.SearchCriteria{{text("a"), text("b")}}
+		})},
+		{query: "a b OR c", want: criteria(func(c *imap.SearchCriteria) {
+			c.Or = [][2]*imap.SearchCriteria{{text("a", "b"), text("c")}}
+		})},
+		{query: "a OR b OR c", want: criteria(func(c *imap.SearchCriteria) {
+			ab := criteria(func(c *imap.SearchCriteria) { c.Or = [][2]*imap.SearchCriteria{{text("a"), text("b")}} })
+			c.Or = [][2]*imap.SearchCriteria{{ab, text("c")}}
+		})},
+		{query: "x (a OR b) -c -(d e)", want: criteria(func(c *imap.SearchCriteria) {
+			c.Text = []string{"x"}
+			c.Or = [][2]*imap.SearchCriteria{{text("a"), text("b")}}
+			c.Not = []*imap.SearchCriteria{text("c"), text("d", "e")}
+		})},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", tt.query, err)
+			continue
+		}
+		if got := q.Criteria(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseQuery(%q).Criteria() = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		col   int
+		msg   string
+	}{
+		{query: `subject:"unterminated`, col: 9, msg: "missing closing quote"},
+		{query: "(from:alice", col: 1, msg: "missing closing )"},
+		{query: "from:alice)", col: 11, msg: "unexpected )"},
+		{query: "a ()", col: 3, msg: "empty group"},
+		{query: "OR deploy", col: 1, msg: "OR needs a term on both sides"},
+		{query: "deploy OR", col: 8, msg: "OR needs a term on both sides"},
+		{query: "(-)", col: 2, msg: "nothing to negate after -"},
+		{query: "deploy from:", col: 8, msg: "from: needs a value"},
+		{query: "is:bogus", col: 1, msg: "unknown is:bogus, try unread, read, flagged, answered or draft"},
+		{query: "has:pdf", col: 1, msg: "unknown has:pdf, try has:attachment"},
+		{query: "after:yesterday", col: 1, msg: `invalid date "yesterday", use YYYY-MM-DD`},
+		{query: "before:2026-13-01", col: 1, msg: `invalid date "2026-13-01", use YYYY-MM-DD`},
+		{query: "a color:red", col: 3, msg: "unknown field color:"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		var qerr *QueryError
+		if !errors.As(err, &qerr) {
+			t.Errorf("ParseQuery(%q) error = %v, want a QueryError", tt.query, err)
+			continue
+		}
+		if qerr.Col != tt.col || qerr.Msg != tt.msg {
+			t.Errorf("ParseQuery(%q) error = column %d: %s, want column %d: %s", tt.query, qerr.Col, qerr.Msg, tt.col, tt.msg)
+		}
+	}
+}
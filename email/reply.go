@@ -0,0 +1,107 @@
+package email
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reply prepares a reply to the original message. With all set, every other
+// recipient of the original is copied, except the user's own address.
+func Reply(original Email, all bool, self string) *OutgoingMessage {
+	to := original.ReplyTo
+	if len(to) == 0 && original.From.Addr != "" {
+		to = []Address{original.From}
+	}
+
+	var cc []Address
+	if all {
+		seen := map[string]bool{strings.ToLower(self): true}
+		for _, a := range to {
+			seen[strings.ToLower(a.Addr)] = true
+		}
+		for _, a := range append(append([]Address{}, original.To...), original.Cc...) {
+			if !seen[strings.ToLower(a.Addr)] {
+				seen[strings.ToLower(a.Addr)] = true
+				cc = append(cc, a)
+			}
+		}
+	}
+
+	msg := &OutgoingMessage{
+		To:      addressStrings(to),
+		Cc:      addressStrings(cc),
+		Subject: prefixSubject("Re: ", original.Subject),
+		Body:    "\n\n" + quoteBody(original),
+	}
+
+	// Thread the reply under the original
+	if original.MessageID != "" {
+		msg.InReplyTo = original.MessageID
+		msg.References = append(threadReferences(original), original.MessageID)
+	}
+
+	return msg
+}
+
+// Forward prepares a message forwarding the original inline
+func Forward(original Email) *OutgoingMessage {
+	var b strings.Builder
+	b.WriteString("\n\n---------- Forwarded message ----------\n")
+	fmt.Fprintf(&b, "From: %s\n", original.From)
+	fmt.Fprintf(&b, "Date: %s\n", original.Date)
+	fmt.Fprintf(&b, "Subject: %s\n", original.Subject)
+	if len(original.To) > 0 {
+		fmt.Fprintf(&b, "To: %s\n", FormatAddresses(original.To))
+	}
+	if len(original.Cc) > 0 {
+		fmt.Fprintf(&b, "Cc: %s\n", FormatAddresses(original.Cc))
+	}
+	b.WriteString("\n")
+	b.WriteString(original.Body)
+
+	msg := &OutgoingMessage{
+		Subject: prefixSubject("Fwd: ", original.Subject),
+		Body:    b.String(),
+	}
+	if original.MessageID != "" {
+		msg.References = append(threadReferences(original), original.MessageID)
+	}
+
+	return msg
+}
+
+// threadReferences returns the ancestors of a message, falling back to
+// In-Reply-To when the original had no References header
+func threadReferences(original Email) []string {
+	if len(original.References) > 0 {
+		return append([]string{}, original.References...)
+	}
+	if original.InReplyTo != "" {
+		return []string{original.InReplyTo}
+	}
+	return nil
+}
+
+// prefixSubject adds a Re:/Fwd: style prefix unless it is already there
+func prefixSubject(prefix, subject string) string {
+	if strings.HasPrefix(strings.ToLower(subject), strings.ToLower(prefix)) {
+		return subject
+	}
+	return prefix + subject
+}
+
+// quoteBody prefixes every line of the original body with "> "
+func quoteBody(original Email) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "On %s, %s wrote:\n", original.Date, original.From)
+
+	for _, line := range strings.Split(strings.TrimRight(original.Body, "\n"), "\n") {
+		if line == "" || strings.HasPrefix(line, ">") {
+			b.WriteString(">" + line + "\n")
+		} else {
+			b.WriteString("> " + line + "\n")
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,157 @@
+package email
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/commands"
+	"github.com/emersion/go-imap/responses"
+)
+
+// Date layouts accepted in search queries
+var searchDateLayouts = []string{"2006-01-02", "2006/01/02"}
+
+// splitQuery splits a query on spaces outside double quotes, for passing it
+// on to Gmail
+func splitQuery(query string) []string {
+	var tokens []string
+	var cur strings.Builder
+	quoted := false
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case r == ' ' && !quoted:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens
+}
+
+// parseSearchDate reads a date from a search query
+func parseSearchDate(s string) (time.Time, error) {
+	for _, layout := range searchDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD", s)
+}
+
+// Search runs a query against a whole mailbox on the server and fetches up
+// to limit of the newest matching messages, newest first. It also returns
+// how many messages matched in total. Words without a field of their own
+// are limited to field unless it is empty, as with ParseQueryIn. Gmail
+// gets an unlimited query through X-GM-RAW, so its own search syntax works
+// there too, even where ParseQueryIn would reject it.
+func (s *Session) Search(mailbox, query, field string, limit uint32) ([]Email, int, error) {
+	// Whether a query the parser rejects can still be sent to Gmail is only
+	// known once connected
+	q, parseErr := ParseQueryIn(query, field)
+	if parseErr != nil && field != "" {
+		return nil, 0, parseErr
+	}
+
+	var emails []Email
+	total := 0
+	err := s.Do(func(c *client.Client) error {
+		mbox, err := s.selectForUIDs(c, mailbox)
+		if err != nil {
+			return err
+		}
+
+		gmail, err := c.Support("X-GM-EXT-1")
+		if err != nil {
+			return fmt.Errorf("failed to check server capabilities: %w", err)
+		}
+
+		var uids []uint32
+		if gmail && field == "" {
+			uids, err = gmailSearch(c, gmailQuery(query))
+		} else if parseErr != nil {
+			return parseErr
+		} else {
+			uids, err = c.UidSearch(q.Criteria())
+		}
+		if err != nil {
+			return fmt.Errorf("failed to search %s: %w", mailbox, err)
+		}
+
+		total = len(uids)
+		if total == 0 {
+			emails = []Email{}
+			return nil
+		}
+
+		sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+		if uint32(len(uids)) > limit {
+			uids = uids[uint32(len(uids))-limit:]
+		}
+
+		seqSet := new(imap.SeqSet)
+		seqSet.AddNum(uids...)
+
+		emails, err = fetchMessages(c, mbox, seqSet, true)
+		return err
+	})
+	return emails, total, err
+}
+
+// gmailQuery rewrites the date words of a query the way Gmail spells them
+func gmailQuery(query string) string {
+	tokens := splitQuery(query)
+	for i, tok := range tokens {
+		field, value, _ := strings.Cut(tok, ":")
+		switch strings.ToLower(field) {
+		case "after", "since", "before":
+			if t, err := parseSearchDate(value); err == nil {
+				if strings.EqualFold(field, "since") {
+					field = "after"
+				}
+				tokens[i] = field + ":" + t.Format("2006/01/02")
+			}
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// gmailSearchCommand is a UID SEARCH using Gmail's X-GM-RAW extension
+type gmailSearchCommand struct {
+	query string
+}
+
+func (cmd *gmailSearchCommand) Command() *imap.Command {
+	return &imap.Command{
+		Name: "SEARCH",
+		Arguments: []interface{}{
+			imap.RawString("CHARSET"),
+			imap.RawString("UTF-8"),
+			imap.RawString("X-GM-RAW"),
+			cmd.query,
+		},
+	}
+}
+
+// gmailSearch runs a query with Gmail's own search syntax
+func gmailSearch(c *client.Client, query string) ([]uint32, error) {
+	res := new(responses.Search)
+	status, err := c.Execute(&commands.Uid{Cmd: &gmailSearchCommand{query: query}}, res)
+	if err == nil {
+		err = status.Err()
+	}
+	return res.Ids, err
+}
@@ -0,0 +1,26 @@
+package email
+
+import "testing"
+
+func TestGmailQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "deploy failed", want: "deploy failed"},
+		{query: "after:2026-01-31", want: "after:2026/01/31"},
+		{query: "since:2026-01-31", want: "after:2026/01/31"},
+		{query: "before:2026/02/28", want: "before:2026/02/28"},
+		{query: "BEFORE:2026-02-28", want: "BEFORE:2026/02/28"},
+		{query: "after:yesterday", want: "after:yesterday"},
+		{query: "in:sent larger:5M after:2026-01-01", want: "in:sent larger:5M after:2026/01/01"},
+		{query: `subject:"after:2026-01-01" before:2026-03-01`, want: `subject:"after:2026-01-01" before:2026/03/01`},
+		{query: "  from:alice   category:updates ", want: "from:alice category:updates"},
+	}
+
+	for _, tt := range tests {
+		if got := gmailQuery(tt.query); got != tt.want {
+			t.Errorf("gmailQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Zachkp/GoMail/config"
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// Limits on connecting to the IMAP server and on each command of the shared
+// connection. A command that times out drops the connection, so it is
+// retried on a fresh one instead of hanging on a connection that went away
+// without closing, such as after the machine slept.
+const (
+	imapDialTimeout    = 30 * time.Second
+	imapCommandTimeout = 2 * time.Minute
+)
+
+// Session owns a single authenticated IMAP connection that is shared for
+// the lifetime of the TUI. The connection is re-established transparently
+// when the network drops or the server sends BYE.
+type Session struct {
+	mu     sync.Mutex
+	cfg    *config.Config
+	client *client.Client
+	bodies *bodyCache
+
+	// Special-use attribute to mailbox name, filled in as they are looked up
+	specialUse map[string]string
+
+	// UIDVALIDITY of each mailbox as of its last full load
+	validityMu sync.Mutex
+	validity   map[string]uint32
+
+	// Dedicated connection that idles on the open mailbox
+	watchMu sync.Mutex
+	watcher *Watcher
+
+	// Directory for files that only live as long as the session
+	tempMu  sync.Mutex
+	tempDir string
+}
+
+// NewSession creates a session for the given configuration. The connection
+// is opened lazily on first use.
+func NewSession(cfg *config.Config) *Session {
+	return &Session{
+		cfg:        cfg,
+		bodies:     newBodyCache(bodyCacheSize),
+		specialUse: make(map[string]string),
+		validity:   make(map[string]uint32),
+	}
+}
+
+// Do runs fn with the shared client, connecting first if needed. If fn fails
+// because the connection was lost it is retried once on a fresh connection.
+func (s *Session) Do(fn func(c *client.Client) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.ensureClient()
+	if err != nil {
+		return err
+	}
+
+	err = fn(c)
+	if err == nil || !s.connectionLost(c, err) {
+		return err
+	}
+
+	// The connection went away underneath us, reconnect and try again
+	s.dropClient()
+	c, err = s.ensureClient()
+	if err != nil {
+		return err
+	}
+
+	return fn(c)
+}
+
+// Config returns the configuration the session was created with
+func (s *Session) Config() *config.Config {
+	return s.cfg
+}
+
+// Username returns the address the session logs in and sends as
+func (s *Session) Username() string {
+	return s.cfg.EmailUsername
+}
+
+// TempDir returns a new directory for files that are only needed while the
+// session is open, such as attachments handed to another program. Close
+// removes it along with everything else it created.
+func (s *Session) TempDir() (string, error) {
+	s.tempMu.Lock()
+	defer s.tempMu.Unlock()
+
+	if s.tempDir == "" {
+		dir, err := os.MkdirTemp("", "gomail-")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		s.tempDir = dir
+	}
+
+	dir, err := os.MkdirTemp(s.tempDir, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	return dir, nil
+}
+
+// Close logs out of the server if a connection is open and removes the
+// session's temporary files
+func (s *Session) Close() error {
+	s.tempMu.Lock()
+	if s.tempDir != "" {
+		os.RemoveAll(s.tempDir)
+		s.tempDir = ""
+	}
+	s.tempMu.Unlock()
+
+	s.watchMu.Lock()
+	if s.watcher != nil {
+		s.watcher.Stop()
+		s.watcher = nil
+	}
+	s.watchMu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil {
+		return nil
+	}
+
+	err := s.client.Logout()
+	s.client = nil
+	if errors.Is(err, client.ErrAlreadyLoggedOut) {
+		return nil
+	}
+	return err
+}
+
+// ensureClient returns the current client, dialing a new one if there is
+// none or the old one has been logged out. Callers must hold s.mu.
+func (s *Session) ensureClient() (*client.Client, error) {
+	if s.client != nil && !isLoggedOut(s.client) {
+		return s.client, nil
+	}
+
+	s.dropClient()
+
+	c, err := dial(s.cfg, imapCommandTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	s.client = c
+	return c, nil
+}
+
+// dropClient discards the current client. Callers must hold s.mu.
+func (s *Session) dropClient() {
+	if s.client != nil {
+		s.client.Terminate()
+		s.client = nil
+	}
+}
+
+// connectionLost reports whether err was caused by the connection going away
+func (s *Session) connectionLost(c *client.Client, err error) bool {
+	if isLoggedOut(c) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, client.ErrNotLoggedIn) ||
+		errors.As(err, &netErr)
+}
+
+// isLoggedOut reports whether the client's connection has been closed
+func isLoggedOut(c *client.Client) bool {
+	if c.State() == imap.LogoutState {
+		return true
+	}
+
+	select {
+	case <-c.LoggedOut():
+		return true
+	default:
+		return false
+	}
+}
+
+// selectMailbox selects the mailbox unless it is already the selected one
+func selectMailbox(c *client.Client, name string) (*imap.MailboxStatus, error) {
+	if mbox := c.Mailbox(); mbox != nil && mbox.Name == name {
+		return mbox, nil
+	}
+
+	mbox, err := c.Select(name, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select %s: %w", name, err)
+	}
+	return mbox, nil
+}
+
+// selectForUIDs selects a mailbox for an operation on UIDs the caller got
+// from an earlier load. If the server has since changed the mailbox's
+// UIDVALIDITY those UIDs may name other messages, so ErrUIDValidityChanged
+// is returned instead and the mailbox must be loaded again.
+func (s *Session) selectForUIDs(c *client.Client, name string) (*imap.MailboxStatus, error) {
+	mbox, err := selectMailbox(c, name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.validityMu.Lock()
+	defer s.validityMu.Unlock()
+
+	known, ok := s.validity[name]
+	if !ok {
+		s.validity[name] = mbox.UidValidity
+	} else if known != mbox.UidValidity {
+		return nil, fmt.Errorf("%s: %w", name, ErrUIDValidityChanged)
+	}
+	return mbox, nil
+}
+
+// setValidity records the UIDVALIDITY a mailbox was fully loaded with
+func (s *Session) setValidity(name string, validity uint32) {
+	s.validityMu.Lock()
+	defer s.validityMu.Unlock()
+	s.validity[name] = validity
+}
+
+// uidValidity returns the UIDVALIDITY a mailbox was last loaded with
+func (s *Session) uidValidity(name string) uint32 {
+	s.validityMu.Lock()
+	defer s.validityMu.Unlock()
+	return s.validity[name]
+}
+
+// dial connects to the IMAP server and logs in. Each command on the
+// connection may take up to timeout, or as long as it likes if that is zero.
+func dial(cfg *config.Config, timeout time.Duration) (*client.Client, error) {
+	dialer := &net.Dialer{Timeout: imapDialTimeout}
+	c, err := client.DialWithDialerTLS(dialer, net.JoinHostPort(cfg.EmailImapHost, cfg.EmailImapPort), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s:%s: %w", cfg.EmailImapHost, cfg.EmailImapPort, err)
+	}
+	c.Timeout = timeout
+
+	if err := c.Login(cfg.EmailUsername, cfg.EmailPassword); err != nil {
+		c.Logout()
+		return nil, fmt.Errorf("login failed for %s: %w", cfg.EmailUsername, err)
+	}
+
+	return c, nil
+}
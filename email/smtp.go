@@ -0,0 +1,218 @@
+package email
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/Zachkp/GoMail/config"
+	"github.com/emersion/go-imap"
+)
+
+// Port that uses implicit TLS when the TLS mode is auto; every other port
+// uses STARTTLS
+const implicitTLSPort = "465"
+
+// Limits on connecting to the SMTP server and on the whole exchange, so a
+// dead server cannot hang a send
+const (
+	smtpDialTimeout    = 30 * time.Second
+	smtpSessionTimeout = 5 * time.Minute
+)
+
+// SaveError reports that a message was sent but could not be stored in the
+// Sent mailbox
+type SaveError struct {
+	Err error
+}
+
+func (e *SaveError) Error() string {
+	return fmt.Sprintf("message sent but not saved: %v", e.Err)
+}
+
+func (e *SaveError) Unwrap() error {
+	return e.Err
+}
+
+// Send builds the message, submits it through the configured SMTP server
+// and then saves a copy to the Sent mailbox. A failure to save is reported
+// as a *SaveError since the message itself has already gone out.
+func (s *Session) Send(msg *OutgoingMessage) error {
+	rcpts, err := msg.Recipients()
+	if err != nil {
+		return err
+	}
+
+	raw, err := msg.Build(s.cfg.EmailUsername)
+	if err != nil {
+		return err
+	}
+
+	if err := sendSMTP(s.cfg, s.cfg.EmailUsername, rcpts, raw); err != nil {
+		return err
+	}
+
+	if !s.cfg.SaveSent {
+		return nil
+	}
+
+	sent, err := s.sentMailbox()
+	if err != nil {
+		return &SaveError{Err: err}
+	}
+	if err := s.AppendMessage(sent, []string{imap.SeenFlag}, raw); err != nil {
+		return &SaveError{Err: err}
+	}
+	return nil
+}
+
+// sentMailbox returns the configured Sent mailbox or discovers it
+func (s *Session) sentMailbox() (string, error) {
+	return s.configuredMailbox(s.cfg.SentMailbox, "EMAIL_SENT_MAILBOX", imap.SentAttr)
+}
+
+// sendSMTP delivers a raw message to the given recipients
+func sendSMTP(cfg *config.Config, from string, rcpts []string, raw []byte) error {
+	c, err := dialSMTP(cfg)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := authSMTP(c, cfg); err != nil {
+		return err
+	}
+
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("server rejected sender %s: %w", from, err)
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("server rejected recipient %s: %w", rcpt, err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("server refused message data: %w", err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		return fmt.Errorf("failed to send message data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("server rejected message: %w", err)
+	}
+
+	return c.Quit()
+}
+
+// dialSMTP connects to the SMTP server and makes sure the connection is
+// encrypted, either with implicit TLS or with STARTTLS as configured
+func dialSMTP(cfg *config.Config) (*smtp.Client, error) {
+	addr := net.JoinHostPort(cfg.EmailSmtpHost, cfg.EmailSmtpPort)
+	tlsConfig := &tls.Config{ServerName: cfg.EmailSmtpHost}
+	dialer := &net.Dialer{Timeout: smtpDialTimeout}
+
+	implicit := cfg.SmtpTLS == config.SmtpTLSImplicit ||
+		(cfg.SmtpTLS != config.SmtpTLSStartTLS && cfg.EmailSmtpPort == implicitTLSPort)
+
+	var conn net.Conn
+	var err error
+	if implicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(smtpSessionTimeout)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	c, err := smtp.NewClient(conn, cfg.EmailSmtpHost)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start SMTP session with %s: %w", addr, err)
+	}
+	if implicit {
+		return c, nil
+	}
+
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		c.Close()
+		return nil, fmt.Errorf("%s does not support STARTTLS", addr)
+	}
+	if err := c.StartTLS(tlsConfig); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("STARTTLS with %s failed: %w", addr, err)
+	}
+
+	return c, nil
+}
+
+// authSMTP logs in with PLAIN or LOGIN, whichever the server offers
+func authSMTP(c *smtp.Client, cfg *config.Config) error {
+	ok, mechs := c.Extension("AUTH")
+	if !ok {
+		return errors.New("SMTP server does not support authentication")
+	}
+
+	var auth smtp.Auth
+	switch {
+	case hasMechanism(mechs, "PLAIN"):
+		auth = smtp.PlainAuth("", cfg.EmailUsername, cfg.EmailPassword, cfg.EmailSmtpHost)
+	case hasMechanism(mechs, "LOGIN"):
+		auth = &loginAuth{username: cfg.EmailUsername, password: cfg.EmailPassword}
+	default:
+		return fmt.Errorf("no supported SMTP auth mechanism in %q", mechs)
+	}
+
+	if err := c.Auth(auth); err != nil {
+		return fmt.Errorf("SMTP login failed for %s: %w", cfg.EmailUsername, err)
+	}
+	return nil
+}
+
+// hasMechanism reports whether an AUTH extension parameter lists mech
+func hasMechanism(mechs, mech string) bool {
+	for _, m := range strings.Fields(mechs) {
+		if strings.EqualFold(m, mech) {
+			return true
+		}
+	}
+	return false
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp lacks
+type loginAuth struct {
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS {
+		return "", nil, errors.New("refusing LOGIN auth over an unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+	}
+}
@@ -0,0 +1,101 @@
+package email
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Template renders the message as an editable text file: a header block,
+// a blank line, then the body
+func (m *OutgoingMessage) Template() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "To: %s\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&b, "Cc: %s\n", strings.Join(m.Cc, ", "))
+	fmt.Fprintf(&b, "Bcc: %s\n", strings.Join(m.Bcc, ", "))
+	fmt.Fprintf(&b, "Subject: %s\n", m.Subject)
+	for _, path := range m.Attachments {
+		fmt.Fprintf(&b, "Attach: %s\n", path)
+	}
+	b.WriteString("\n")
+	b.WriteString(m.Body)
+	return b.String()
+}
+
+// ParseTemplate reads a file written by Template back into a message.
+// Threading headers are carried over from base since they are not editable.
+func ParseTemplate(text string, base *OutgoingMessage) (*OutgoingMessage, error) {
+	msg := &OutgoingMessage{}
+	if base != nil {
+		msg.InReplyTo = base.InReplyTo
+		msg.References = base.References
+		msg.MessageID = base.MessageID
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lastKey string
+	var attachments []string
+	headers := make(map[string]string)
+	lineNum := 0
+	bodyStart := len(text)
+	offset := 0
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNum++
+		offset += len(line) + 1
+
+		if strings.TrimSpace(line) == "" {
+			bodyStart = offset
+			break
+		}
+
+		// Folded header lines continue the previous header
+		if (line[0] == ' ' || line[0] == '\t') && lastKey != "" {
+			headers[lastKey] += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"Header: value\", got %q", lineNum, line)
+		}
+		lastKey = strings.ToLower(strings.TrimSpace(name))
+
+		// Attach may be repeated, once per file
+		if lastKey == "attach" {
+			if path := strings.TrimSpace(value); path != "" {
+				attachments = append(attachments, path)
+			}
+			lastKey = ""
+			continue
+		}
+		headers[lastKey] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	for key := range headers {
+		switch key {
+		case "to", "cc", "bcc", "subject":
+		default:
+			return nil, fmt.Errorf("unknown header %q", key)
+		}
+	}
+
+	msg.To = SplitAddresses(headers["to"])
+	msg.Cc = SplitAddresses(headers["cc"])
+	msg.Bcc = SplitAddresses(headers["bcc"])
+	msg.Subject = headers["subject"]
+	msg.Attachments = attachments
+	if bodyStart < len(text) {
+		msg.Body = text[bodyStart:]
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,378 @@
+package email
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/commands"
+	"github.com/emersion/go-imap/responses"
+)
+
+// ThreadNode is a message in a conversation tree. UID is zero for a
+// placeholder standing in for a message that is not in the mailbox, such as
+// the start of a thread that was deleted.
+type ThreadNode struct {
+	UID      uint32
+	Children []*ThreadNode
+}
+
+// Threads groups the given messages of a mailbox into conversations. The
+// server does the work when it supports THREAD=REFERENCES (RFC 5256);
+// otherwise the messages are threaded locally from their headers.
+func (s *Session) Threads(mailbox string, emails []Email) ([]*ThreadNode, error) {
+	if len(emails) == 0 {
+		return nil, nil
+	}
+	uids := new(imap.SeqSet)
+	for _, e := range emails {
+		uids.AddNum(e.UID)
+	}
+
+	var roots []*ThreadNode
+	supported := false
+	err := s.Do(func(c *client.Client) error {
+		ok, err := c.Support("THREAD=REFERENCES")
+		if err != nil {
+			return fmt.Errorf("failed to check server capabilities: %w", err)
+		}
+		if !ok {
+			return nil
+		}
+		supported = true
+
+		if _, err := s.selectForUIDs(c, mailbox); err != nil {
+			return err
+		}
+
+		// Only the loaded messages are wanted, not everything that arrived
+		// after the oldest of them
+		res := &threadResponse{}
+		cmd := &commands.Uid{Cmd: &threadCommand{
+			algorithm: "REFERENCES",
+			uids:      uids,
+		}}
+		status, err := c.Execute(cmd, res)
+		if err == nil {
+			err = status.Err()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to thread messages: %w", err)
+		}
+		roots = res.roots
+		return nil
+	})
+	if err != nil || supported {
+		return roots, err
+	}
+
+	return ThreadEmails(emails), nil
+}
+
+// threadCommand is a THREAD command, as defined in RFC 5256 section 3
+type threadCommand struct {
+	algorithm string
+	uids      *imap.SeqSet
+}
+
+func (cmd *threadCommand) Command() *imap.Command {
+	return &imap.Command{
+		Name: "THREAD",
+		Arguments: []interface{}{
+			imap.RawString(cmd.algorithm),
+			imap.RawString("UTF-8"),
+			imap.RawString("UID"),
+			imap.RawString(cmd.uids.String()),
+		},
+	}
+}
+
+// threadResponse collects the trees from THREAD responses
+type threadResponse struct {
+	roots []*ThreadNode
+}
+
+func (r *threadResponse) Handle(resp imap.Resp) error {
+	name, fields, ok := imap.ParseNamedResp(resp)
+	if !ok || name != "THREAD" {
+		return responses.ErrUnhandled
+	}
+
+	for _, f := range fields {
+		list, ok := f.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid thread list %v", f)
+		}
+		node, err := parseThreadList(list)
+		if err != nil {
+			return err
+		}
+		r.roots = append(r.roots, node)
+	}
+	return nil
+}
+
+// parseThreadList reads one parenthesized thread, e.g. (3 6 (4 23)(44 7 96)):
+// each number is the parent of the next, and nested lists are sibling
+// subtrees under the last number. A list that starts with nested lists has
+// no known root and gets a placeholder.
+func parseThreadList(list []interface{}) (*ThreadNode, error) {
+	root := &ThreadNode{}
+	cur := root
+	started := false
+
+	for _, f := range list {
+		if sub, ok := f.([]interface{}); ok {
+			child, err := parseThreadList(sub)
+			if err != nil {
+				return nil, err
+			}
+			cur.Children = append(cur.Children, child)
+			started = true
+			continue
+		}
+
+		uid, err := imap.ParseNumber(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid thread member: %w", err)
+		}
+		if !started {
+			root.UID = uid
+			started = true
+			continue
+		}
+		node := &ThreadNode{UID: uid}
+		cur.Children = append(cur.Children, node)
+		cur = node
+	}
+
+	return root, nil
+}
+
+// Reply and forward prefixes and list tags stripped to find a thread's base
+// subject, and the reply prefix on its own
+var (
+	subjectPrefix = regexp.MustCompile(`(?i)^\s*((re|fwd?|aw|sv|antw)(\[\d+\])?:\s*|\[[^\]]*\]\s*)+`)
+	replyPrefix   = regexp.MustCompile(`(?i)^\s*(\[[^\]]*\]\s*)*(re|fwd?|aw|sv|antw)(\[\d+\])?:`)
+)
+
+// BaseSubject strips reply and forward prefixes and list tags from a subject
+func BaseSubject(subject string) string {
+	return strings.ToLower(strings.TrimSpace(subjectPrefix.ReplaceAllString(subject, "")))
+}
+
+// isReply reports whether a subject carries a reply or forward prefix
+func isReply(subject string) bool {
+	return replyPrefix.MatchString(subject)
+}
+
+// container is a node of the threading table, which may hold no message
+type container struct {
+	email    *Email
+	parent   *container
+	children []*container
+}
+
+// hasDescendant reports whether c is d or one of its ancestors
+func (c *container) hasDescendant(d *container) bool {
+	for ; d != nil; d = d.parent {
+		if d == c {
+			return true
+		}
+	}
+	return false
+}
+
+// setParent moves c under parent, or to the root set when parent is nil
+func (c *container) setParent(parent *container) {
+	if c.parent != nil {
+		siblings := c.parent.children
+		for i, s := range siblings {
+			if s == c {
+				c.parent.children = append(siblings[:i:i], siblings[i+1:]...)
+				break
+			}
+		}
+	}
+	c.parent = parent
+	if parent != nil {
+		parent.children = append(parent.children, c)
+	}
+}
+
+// ThreadEmails threads messages locally with Jamie Zawinski's algorithm,
+// linking them through Message-ID, In-Reply-To and References and then
+// joining threads whose root shares a subject with a reply
+func ThreadEmails(emails []Email) []*ThreadNode {
+	table := make(map[string]*container)
+	get := func(id string) *container {
+		c, ok := table[id]
+		if !ok {
+			c = &container{}
+			table[id] = c
+		}
+		return c
+	}
+
+	var order []*container
+	for i := range emails {
+		e := &emails[i]
+
+		// Messages without a usable Message-ID still need a container
+		id := e.MessageID
+		if id == "" || (table[id] != nil && table[id].email != nil) {
+			id = fmt.Sprintf("uid:%d", e.UID)
+		}
+		c := get(id)
+		c.email = e
+		order = append(order, c)
+
+		refs := e.References
+		if len(refs) == 0 && e.InReplyTo != "" {
+			refs = []string{e.InReplyTo}
+		}
+
+		// Link the references in order without undoing earlier links
+		var prev *container
+		for _, ref := range refs {
+			rc := get(ref)
+			if prev != nil && rc.parent == nil && !rc.hasDescendant(prev) {
+				rc.setParent(prev)
+			}
+			prev = rc
+		}
+
+		// The message itself belongs under its last reference
+		if prev != nil && prev != c && !c.hasDescendant(prev) {
+			c.setParent(prev)
+		} else if prev == nil && c.parent != nil {
+			c.setParent(nil)
+		}
+	}
+
+	var roots []*container
+	seen := make(map[*container]bool)
+	for _, c := range table {
+		for c.parent != nil {
+			c = c.parent
+		}
+		if !seen[c] {
+			seen[c] = true
+			roots = append(roots, c)
+		}
+	}
+
+	// Keep the result stable by starting from the message order
+	rank := make(map[*container]int, len(order))
+	for i, c := range order {
+		rank[c] = i
+	}
+	first := func(c *container) int {
+		best := len(order)
+		var walk func(*container)
+		walk = func(c *container) {
+			if r, ok := rank[c]; ok && r < best {
+				best = r
+			}
+			for _, child := range c.children {
+				walk(child)
+			}
+		}
+		walk(c)
+		return best
+	}
+	byFirst := make(map[int]*container, len(roots))
+	for _, r := range roots {
+		byFirst[first(r)] = r
+	}
+	roots = roots[:0]
+	for i := 0; i <= len(order); i++ {
+		if r, ok := byFirst[i]; ok {
+			roots = append(roots, r)
+		}
+	}
+
+	roots = pruneContainers(roots, true)
+	roots = groupBySubject(roots)
+
+	var nodes []*ThreadNode
+	for _, r := range roots {
+		nodes = append(nodes, toThreadNode(r))
+	}
+	return nodes
+}
+
+// pruneContainers drops empty containers, promoting their children. At the
+// top level an empty container is only dropped if it holds a single thread,
+// so unrelated replies to the same missing message stay together.
+func pruneContainers(list []*container, top bool) []*container {
+	var out []*container
+	for _, c := range list {
+		c.children = pruneContainers(c.children, false)
+		if c.email != nil {
+			out = append(out, c)
+			continue
+		}
+		switch {
+		case len(c.children) == 0:
+		case !top || len(c.children) == 1:
+			for _, child := range c.children {
+				child.parent = c.parent
+			}
+			out = append(out, c.children...)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// groupBySubject makes roots that reply to another root's subject its
+// children, for clients that drop the threading headers
+func groupBySubject(roots []*container) []*container {
+	subjectOf := func(c *container) string {
+		if c.email != nil {
+			return c.email.Subject
+		}
+		if len(c.children) > 0 && c.children[0].email != nil {
+			return c.children[0].email.Subject
+		}
+		return ""
+	}
+
+	bySubject := make(map[string]*container)
+	for _, r := range roots {
+		base := BaseSubject(subjectOf(r))
+		if base == "" {
+			continue
+		}
+		if existing, ok := bySubject[base]; !ok || (isReply(subjectOf(existing)) && !isReply(subjectOf(r))) {
+			bySubject[base] = r
+		}
+	}
+
+	var out []*container
+	for _, r := range roots {
+		base := BaseSubject(subjectOf(r))
+		if owner := bySubject[base]; base != "" && owner != r && owner.email != nil && isReply(subjectOf(r)) {
+			r.setParent(owner)
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// toThreadNode converts a container tree into the exported form
+func toThreadNode(c *container) *ThreadNode {
+	node := &ThreadNode{}
+	if c.email != nil {
+		node.UID = c.email.UID
+	}
+	for _, child := range c.children {
+		node.Children = append(node.Children, toThreadNode(child))
+	}
+	return node
+}
@@ -0,0 +1,174 @@
+package email
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-imap"
+)
+
+// treeString writes thread trees compactly, e.g. 3(6(4 44)) for 3 with a
+// reply 6 that has replies 4 and 44. Placeholders are written as _.
+func treeString(nodes []*ThreadNode) string {
+	var parts []string
+	for _, n := range nodes {
+		s := "_"
+		if n.UID != 0 {
+			s = fmt.Sprint(n.UID)
+		}
+		if len(n.Children) > 0 {
+			s += "(" + treeString(n.Children) + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestThreadResponse(t *testing.T) {
+	tests := []struct {
+		resp string
+		want string
+		err  bool
+	}{
+		{resp: "* THREAD", want: ""},
+		{resp: "* THREAD (2)(3 6 (4 23)(44 7 96))", want: "2 3(6(4(23) 44(7(96))))"},
+		{resp: "* THREAD ((3)(5))", want: "_(3 5)"},
+		{resp: "* THREAD (1 (2)(3 4))", want: "1(2 3(4))"},
+		{resp: "* THREAD (1 2 3 4)", want: "1(2(3(4)))"},
+		{resp: "* THREAD (1 x)", err: true},
+		{resp: "* THREAD 1", err: true},
+	}
+
+	for _, tt := range tests {
+		r := imap.NewReader(bufio.NewReader(strings.NewReader(tt.resp + "\r\n")))
+		resp, err := imap.ReadResp(r)
+		if err != nil {
+			t.Fatalf("failed to read %q: %v", tt.resp, err)
+		}
+
+		res := &threadResponse{}
+		err = res.Handle(resp)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q parsed as %s, want an error", tt.resp, treeString(res.roots))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q failed: %v", tt.resp, err)
+			continue
+		}
+		if got := treeString(res.roots); got != tt.want {
+			t.Errorf("%q = %s, want %s", tt.resp, got, tt.want)
+		}
+	}
+}
+
+func TestThreadEmails(t *testing.T) {
+	tests := []struct {
+		name   string
+		emails []Email
+		want   string
+	}{
+		{
+			name: "references",
+			emails: []Email{
+				{UID: 1, MessageID: "a", Subject: "Plans"},
+				{UID: 2, MessageID: "b", Subject: "Re: Plans", References: []string{"a"}},
+				{UID: 3, MessageID: "c", Subject: "Re: Plans", References: []string{"a", "b"}},
+			},
+			want: "1(2(3))",
+		},
+		{
+			name: "in-reply-to without references",
+			emails: []Email{
+				{UID: 1, MessageID: "a", Subject: "Plans"},
+				{UID: 2, MessageID: "b", Subject: "Re: Plans", InReplyTo: "a"},
+			},
+			want: "1(2)",
+		},
+		{
+			name: "shared references",
+			emails: []Email{
+				{UID: 1, MessageID: "a", Subject: "Plans"},
+				{UID: 2, MessageID: "b", Subject: "Re: Plans", References: []string{"a"}},
+				{UID: 3, MessageID: "c", Subject: "Re: Plans", References: []string{"a"}},
+			},
+			want: "1(2 3)",
+		},
+		{
+			name: "reply loaded before its parent",
+			emails: []Email{
+				{UID: 2, MessageID: "b", Subject: "Re: Plans", References: []string{"a"}},
+				{UID: 1, MessageID: "a", Subject: "Plans"},
+			},
+			want: "1(2)",
+		},
+		{
+			name: "one reply to a missing message",
+			emails: []Email{
+				{UID: 2, MessageID: "b", Subject: "Re: Plans", References: []string{"missing"}},
+			},
+			want: "2",
+		},
+		{
+			name: "replies to a missing message stay together",
+			emails: []Email{
+				{UID: 2, MessageID: "b", Subject: "Re: Plans", References: []string{"missing"}},
+				{UID: 3, MessageID: "c", Subject: "Re: Plans", References: []string{"missing"}},
+			},
+			want: "_(2 3)",
+		},
+		{
+			name: "missing message in the middle of references",
+			emails: []Email{
+				{UID: 1, MessageID: "a", Subject: "Plans"},
+				{UID: 3, MessageID: "c", Subject: "Re: Plans", References: []string{"a", "missing"}},
+			},
+			want: "1(3)",
+		},
+		{
+			name: "reply without headers joins by subject",
+			emails: []Email{
+				{UID: 1, MessageID: "a", Subject: "Plans"},
+				{UID: 2, MessageID: "b", Subject: "RE: [team] Plans"},
+				{UID: 3, MessageID: "c", Subject: "Plans for lunch"},
+			},
+			want: "1(2) 3",
+		},
+		{
+			name: "duplicate message ids",
+			emails: []Email{
+				{UID: 1, MessageID: "a", Subject: "Plans"},
+				{UID: 2, MessageID: "a", Subject: "Lunch"},
+			},
+			want: "1 2",
+		},
+		{
+			name: "threads keep the message order",
+			emails: []Email{
+				{UID: 9, MessageID: "z", Subject: "Lunch"},
+				{UID: 2, MessageID: "b", Subject: "Re: Plans", References: []string{"a"}},
+				{UID: 1, MessageID: "a", Subject: "Plans"},
+				{UID: 5, Subject: "No id"},
+			},
+			want: "9 1(2) 5",
+		},
+		{
+			name: "reference loops are ignored",
+			emails: []Email{
+				{UID: 1, MessageID: "a", Subject: "Plans", References: []string{"b"}},
+				{UID: 2, MessageID: "b", Subject: "Re: Plans", References: []string{"a"}},
+			},
+			want: "2(1)",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := treeString(ThreadEmails(tt.emails)); got != tt.want {
+			t.Errorf("%s: ThreadEmails = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
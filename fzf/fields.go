@@ -0,0 +1,63 @@
+// fzf/fields.go
+package fzf
+
+import (
+	"github.com/Zachkp/GoMail/email"
+)
+
+// searchField is a part of a message that query words are looked for in.
+// A word found in a field with a higher weight ranks the message higher.
+type searchField struct {
+	name   string
+	weight int
+	text   func(e email.Email) string
+}
+
+var searchFields = []searchField{
+	{name: "subject", weight: 3, text: func(e email.Email) string { return e.Subject }},
+	{name: "from", weight: 2, text: func(e email.Email) string { return e.From.String() }},
+	{name: "to", weight: 2, text: func(e email.Email) string { return email.FormatAddresses(e.To) }},
+	{name: "cc", weight: 2, text: func(e email.Email) string { return email.FormatAddresses(e.Cc) }},
+	{name: "body", weight: 1, text: func(e email.Email) string { return e.Body }},
+}
+
+// Helper function to find the fields a query word is looked for in; words
+// without a field go to the subject, sender and body
+func fieldsFor(name string) []searchField {
+	var fields []searchField
+	for _, f := range searchFields {
+		if f.name == name || (name == "" && (f.name == "subject" || f.name == "from" || f.name == "body")) {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// Helper function to score a message against the words of a query,
+// counting each word in the field where it scores best
+func scoreTerms(e email.Email, terms []email.Term) int {
+	total := 0
+	for _, t := range terms {
+		best := 0
+		for _, f := range fieldsFor(t.Field) {
+			if s, _, ok := Match(t.Value, f.text(e)); ok && s*f.weight > best {
+				best = s * f.weight
+			}
+		}
+		total += best
+	}
+	return total
+}
+
+// Patterns lists the query words to highlight in a field of a message
+func Patterns(q *email.Query, field string) []string {
+	var patterns []string
+	for _, t := range q.Terms() {
+		for _, f := range fieldsFor(t.Field) {
+			if f.name == field {
+				patterns = append(patterns, t.Value)
+			}
+		}
+	}
+	return patterns
+}
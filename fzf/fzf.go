@@ -2,73 +2,51 @@
 package fzf
 
 import (
-	"strings"
+	"sort"
 
 	"github.com/Zachkp/GoMail/email"
-	"github.com/lithammer/fuzzysearch/fuzzy"
 )
 
-// FuzzySearchEmails searches through emails using fuzzy matching
-func FuzzySearchEmails(emails []email.Email, query string) []email.Email {
-	if query == "" {
-		return emails
-	}
-
-	var results []email.Email
-	var searchTargets []string
-
-	// Create searchable strings from email data
-	for _, e := range emails {
-		searchTarget := strings.ToLower(e.From + " " + e.Subject + " " + e.Body)
-		searchTargets = append(searchTargets, searchTarget)
-	}
-
-	// Find matches using fuzzy search
-	matches := fuzzy.RankFindFold(strings.ToLower(query), searchTargets)
-
-	// Sort by rank and return matching emails
-	for _, match := range matches {
-		if match.Distance <= len(query)*2 { // Adjust threshold as needed
-			results = append(results, emails[match.OriginalIndex])
-		}
-	}
+// Fields a search can be limited to. The empty field searches the sender,
+// subject and body.
+var Fields = []string{"", "subject", "from", "to", "cc", "body"}
 
-	return results
+// Result is a message matching a query along with its score
+type Result struct {
+	Email email.Email
+	Score int
 }
 
-// FuzzySearchEmailsByField searches emails by specific field
-func FuzzySearchEmailsByField(emails []email.Email, query string, field string) []email.Email {
-	if query == "" {
-		return emails
-	}
-
-	var results []email.Email
-	var searchTargets []string
+// Matches is the email.TextMatcher that matches query words the way Match
+// does, so every search shares one threshold
+func Matches(pattern, text string) bool {
+	_, _, ok := Match(pattern, text)
+	return ok
+}
 
-	// Create searchable strings based on field
+// Rank filters messages with a query and orders them best match first.
+// This is the search behind both the search bar and the search command.
+func Rank(emails []email.Email, q *email.Query) []Result {
+	var matched []email.Email
 	for _, e := range emails {
-		var searchTarget string
-		switch field {
-		case "from":
-			searchTarget = strings.ToLower(e.From)
-		case "subject":
-			searchTarget = strings.ToLower(e.Subject)
-		case "body":
-			searchTarget = strings.ToLower(e.Body)
-		default:
-			searchTarget = strings.ToLower(e.From + " " + e.Subject + " " + e.Body)
+		if q.Match(e, Matches) {
+			matched = append(matched, e)
 		}
-		searchTargets = append(searchTargets, searchTarget)
 	}
+	return Order(matched, q)
+}
 
-	// Find matches
-	matches := fuzzy.RankFindFold(strings.ToLower(query), searchTargets)
-
-	for _, match := range matches {
-		if match.Distance <= len(query)*2 {
-			results = append(results, emails[match.OriginalIndex])
-		}
+// Order ranks messages already known to match a query, such as the results
+// of a server search, best match first. Messages that score the same keep
+// their order.
+func Order(emails []email.Email, q *email.Query) []Result {
+	terms := q.Terms()
+	results := make([]Result, len(emails))
+	for i, e := range emails {
+		results[i] = Result{Email: e, Score: scoreTerms(e, terms)}
 	}
-
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
 	return results
 }
@@ -0,0 +1,95 @@
+package fzf
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Zachkp/GoMail/email"
+)
+
+// testEmails is a small mailbox, newest first
+var testEmails = []email.Email{
+	{UID: 6, Subject: "Lunch on Friday", From: email.Address{Name: "Dana", Addr: "dana@example.com"}, Body: "Shall we try the new deploy café?"},
+	{UID: 5, Subject: "Re: Deploy failed", From: email.Address{Name: "CI", Addr: "ci@example.com"}, Body: "The staging deploy failed again."},
+	{UID: 4, Subject: "Weekly report", From: email.Address{Name: "Deploy Bot", Addr: "bot@example.com"}, Body: "Nothing to report."},
+	{UID: 3, Subject: "Deploy failed", From: email.Address{Name: "CI", Addr: "ci@example.com"}, Body: "The production deploy failed."},
+	{UID: 2, Subject: "Invoice", From: email.Address{Name: "Alice", Addr: "alice@example.com"}, Body: "Please find the invoice attached.",
+		To: []email.Address{{Name: "Bob", Addr: "bob@example.com"}}},
+	{UID: 1, Subject: "Undepleted stock", From: email.Address{Name: "Shop", Addr: "shop@example.com"}, Body: "Stock levels are fine."},
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		field string
+		want  []uint32
+	}{
+		{name: "empty query keeps the order", query: "", want: []uint32{6, 5, 4, 3, 2, 1}},
+		{name: "subject beats sender beats body, ties stay newest first", query: "deploy", want: []uint32{5, 3, 4, 6}},
+		{name: "word start beats middle of word", query: "dep", field: "subject", want: []uint32{5, 3, 1}},
+		{name: "field weight beats a better match", query: "dep", want: []uint32{5, 3, 1, 4, 6}},
+		{name: "loose words", query: "dploy", want: []uint32{5, 3, 4, 6}},
+		{name: "field prefix", query: "from:deploy", want: []uint32{4}},
+		{name: "field selector", query: "deploy", field: "subject", want: []uint32{5, 3}},
+		{name: "field selector leaves prefixed words alone", query: "from:alice invoice", field: "subject", want: []uint32{2}},
+		{name: "recipient field", query: "bob", field: "to", want: []uint32{2}},
+		{name: "negated words do not rank", query: "deploy -subject:report", want: []uint32{5, 3, 6}},
+		{name: "OR", query: "invoice OR lunch", want: []uint32{2, 6}},
+		{name: "no match", query: "zebra", want: []uint32{}},
+	}
+
+	for _, tt := range tests {
+		q, err := email.ParseQueryIn(tt.query, tt.field)
+		if err != nil {
+			t.Errorf("%s: failed to parse %q: %v", tt.name, tt.query, err)
+			continue
+		}
+		var got []uint32
+		for _, r := range Rank(testEmails, q) {
+			got = append(got, r.Email.UID)
+		}
+		if !slices.Equal(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+			t.Errorf("%s: Rank(%q) = %v, want %v", tt.name, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	// Server results are kept even when the loaded text does not match
+	q, err := email.ParseQuery("dep")
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+	emails := []email.Email{{UID: 9, Subject: "Status"}, testEmails[3], testEmails[5]}
+
+	var got []uint32
+	for _, r := range Order(emails, q) {
+		got = append(got, r.Email.UID)
+	}
+	if want := []uint32{3, 1, 9}; !slices.Equal(got, want) {
+		t.Errorf("Order = %v, want %v", got, want)
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	q, err := email.ParseQuery("deploy from:ci -body:staging subject:fail")
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+
+	tests := []struct {
+		field string
+		want  []string
+	}{
+		{field: "subject", want: []string{"deploy", "fail"}},
+		{field: "from", want: []string{"deploy", "ci"}},
+		{field: "body", want: []string{"deploy"}},
+		{field: "to", want: nil},
+	}
+	for _, tt := range tests {
+		if got := Patterns(q, tt.field); !slices.Equal(got, tt.want) {
+			t.Errorf("Patterns(%s) = %q, want %q", tt.field, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// fzf/score.go
+package fzf
+
+import (
+	"sort"
+	"unicode"
+)
+
+// Scores modelled on fzf's: every matched character earns points, gaps
+// between matches cost points, and characters at the start of a word earn
+// a bonus, so "dep" ranks "Deploy failed" above "undepleted"
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+
+	// A match at the start of a word
+	bonusBoundary = scoreMatch / 2
+	// A match on punctuation or another non-word character
+	bonusNonWord = scoreMatch / 2
+	// A match at an uppercase letter after a lowercase one, or at a digit
+	// after a letter
+	bonusCamel = bonusBoundary + scoreGapExtension
+	// A match right after the previous one
+	bonusConsecutive = -(scoreGapStart + scoreGapExtension)
+	// The first character of the pattern counts its bonus twice
+	bonusFirstCharMultiplier = 2
+
+	// A match has to average at least this much per pattern character, so
+	// characters scattered over a long text do not count
+	minScorePerChar = scoreMatch / 2
+)
+
+// Character classes used to find word boundaries
+type charClass int
+
+const (
+	classWhite charClass = iota
+	classNonWord
+	classLower
+	classUpper
+	classLetter
+	classNumber
+)
+
+func classOf(r rune) charClass {
+	switch {
+	case unicode.IsSpace(r):
+		return classWhite
+	case unicode.IsLower(r):
+		return classLower
+	case unicode.IsUpper(r):
+		return classUpper
+	case unicode.IsLetter(r):
+		return classLetter
+	case unicode.IsDigit(r):
+		return classNumber
+	}
+	return classNonWord
+}
+
+// bonusFor returns the bonus for matching a character of class cur that
+// follows one of class prev
+func bonusFor(prev, cur charClass) int {
+	switch {
+	case cur > classNonWord && (prev == classWhite || prev == classNonWord):
+		return bonusBoundary
+	case prev == classLower && cur == classUpper,
+		prev != classNumber && cur == classNumber:
+		return bonusCamel
+	case cur == classNonWord:
+		return bonusNonWord
+	}
+	return 0
+}
+
+// Match scores how well pattern matches text, ignoring case, and returns
+// the rune positions of the matched characters. The characters of the
+// pattern have to appear in text in order; an exact occurrence always
+// beats a scattered one. ok is false when there is no good enough match.
+func Match(pattern, text string) (score int, positions []int, ok bool) {
+	pat := foldRunes(pattern)
+	if len(pat) == 0 {
+		return 0, nil, true
+	}
+	runes := []rune(text)
+	folded := foldRunes(text)
+
+	found := false
+	for _, start := range occurrences(folded, pat) {
+		pos := make([]int, len(pat))
+		for i := range pos {
+			pos[i] = start + i
+		}
+		if s := scorePositions(runes, pos); !found || s > score {
+			score, positions, found = s, pos, true
+		}
+	}
+
+	if !found {
+		positions = scatteredPositions(folded, pat)
+		if positions == nil {
+			return 0, nil, false
+		}
+		score = scorePositions(runes, positions)
+	}
+
+	if score < len(pat)*minScorePerChar {
+		return score, nil, false
+	}
+	return score, positions, true
+}
+
+// Positions finds the characters to highlight for a set of patterns: every
+// exact occurrence of a pattern, or its best scattered match when it has
+// none. The positions are runes of text, in order.
+func Positions(patterns []string, text string) []int {
+	folded := foldRunes(text)
+	seen := make(map[int]bool)
+
+	for _, pattern := range patterns {
+		pat := foldRunes(pattern)
+		if len(pat) == 0 {
+			continue
+		}
+		starts := occurrences(folded, pat)
+		for _, start := range starts {
+			for i := range pat {
+				seen[start+i] = true
+			}
+		}
+		if len(starts) == 0 {
+			_, pos, _ := Match(pattern, text)
+			for _, p := range pos {
+				seen[p] = true
+			}
+		}
+	}
+
+	positions := make([]int, 0, len(seen))
+	for p := range seen {
+		positions = append(positions, p)
+	}
+	sort.Ints(positions)
+	return positions
+}
+
+// Helper function to lowercase text rune by rune, so positions in the
+// result are positions in the original
+func foldRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// Helper function to find where pat occurs in text
+func occurrences(text, pat []rune) []int {
+	var starts []int
+	for i := 0; i+len(pat) <= len(text); i++ {
+		j := 0
+		for j < len(pat) && text[i+j] == pat[j] {
+			j++
+		}
+		if j == len(pat) {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// scatteredPositions finds the characters of pat in text in order, the way
+// fzf's first algorithm does: scan forward for where the first match ends,
+// then backward from there for the shortest stretch holding them all
+func scatteredPositions(text, pat []rune) []int {
+	end, j := -1, 0
+	for i, r := range text {
+		if r == pat[j] {
+			j++
+			if j == len(pat) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return nil
+	}
+
+	positions := make([]int, len(pat))
+	j = len(pat) - 1
+	for i := end; i >= 0 && j >= 0; i-- {
+		if text[i] == pat[j] {
+			positions[j] = i
+			j--
+		}
+	}
+	return positions
+}
+
+// scorePositions adds up the score of matching the runes at positions
+func scorePositions(text []rune, positions []int) int {
+	score, chunkBonus := 0, 0
+	for i, p := range positions {
+		prev := classWhite
+		if p > 0 {
+			prev = classOf(text[p-1])
+		}
+		bonus := bonusFor(prev, classOf(text[p]))
+
+		score += scoreMatch
+		switch {
+		case i == 0:
+			score += bonus * bonusFirstCharMultiplier
+			chunkBonus = bonus
+		case p == positions[i-1]+1:
+			// A run of matches keeps the bonus of the character it started at
+			score += max(bonus, chunkBonus, bonusConsecutive)
+		default:
+			gap := p - positions[i-1] - 1
+			score += scoreGapStart + scoreGapExtension*(gap-1) + bonus
+			chunkBonus = bonus
+		}
+	}
+	return score
+}
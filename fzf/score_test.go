@@ -0,0 +1,82 @@
+package fzf
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		text      string
+		ok        bool
+		positions []int
+	}{
+		{pattern: "dep", text: "Deploy failed", ok: true, positions: []int{0, 1, 2}},
+		{pattern: "DEP", text: "deploy failed", ok: true, positions: []int{0, 1, 2}},
+		{pattern: "dploy", text: "Deploy failed", ok: true, positions: []int{0, 2, 3, 4, 5}},
+		{pattern: "fail", text: "Deploy failed", ok: true, positions: []int{7, 8, 9, 10}},
+		{pattern: "café", text: "Le Café crème", ok: true, positions: []int{3, 4, 5, 6}},
+		{pattern: "xyz", text: "Deploy failed", ok: false},
+		{pattern: "yold", text: "Deploy failed", ok: false},
+		{pattern: "abc", text: "a" + string(make([]rune, 200)) + "b" + string(make([]rune, 200)) + "c", ok: false},
+		{pattern: "", text: "anything", ok: true},
+	}
+
+	for _, tt := range tests {
+		_, positions, ok := Match(tt.pattern, tt.text)
+		if ok != tt.ok {
+			t.Errorf("Match(%q, %q) ok = %v, want %v", tt.pattern, tt.text, ok, tt.ok)
+			continue
+		}
+		if tt.ok && !slices.Equal(positions, tt.positions) {
+			t.Errorf("Match(%q, %q) positions = %v, want %v", tt.pattern, tt.text, positions, tt.positions)
+		}
+	}
+}
+
+func TestMatchRanking(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		better  string
+		worse   string
+	}{
+		{name: "word start beats middle of word", pattern: "dep", better: "Deploy failed", worse: "Undepleted stock"},
+		{name: "exact beats scattered", pattern: "report", better: "Weekly report", worse: "Reply: port status"},
+		{name: "consecutive beats gaps", pattern: "sync", better: "Team sync", worse: "Sales by region cancelled"},
+		{name: "camel case counts as a word start", pattern: "ci", better: "GitHub CI failed", worse: "Social event"},
+		{name: "shorter gaps beat longer ones", pattern: "bg", better: "big news", worse: "budget meeting"},
+	}
+
+	for _, tt := range tests {
+		better, _, ok := Match(tt.pattern, tt.better)
+		if !ok {
+			t.Errorf("%s: %q does not match %q", tt.name, tt.pattern, tt.better)
+			continue
+		}
+		worse, _, ok := Match(tt.pattern, tt.worse)
+		if ok && worse >= better {
+			t.Errorf("%s: %q scores %d, not above %d for %q", tt.name, tt.better, better, worse, tt.worse)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		text     string
+		want     []int
+	}{
+		{patterns: []string{"ab"}, text: "ab cab", want: []int{0, 1, 4, 5}},
+		{patterns: []string{"ab", "c"}, text: "ab cab", want: []int{0, 1, 3, 4, 5}},
+		{patterns: []string{"dpl"}, text: "Deploy", want: []int{0, 2, 3}},
+		{patterns: []string{"zz"}, text: "Deploy", want: []int{}},
+	}
+
+	for _, tt := range tests {
+		if got := Positions(tt.patterns, tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("Positions(%q, %q) = %v, want %v", tt.patterns, tt.text, got, tt.want)
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/Zachkp/GoMail/config"
+	"github.com/Zachkp/GoMail/email"
+	"github.com/Zachkp/GoMail/fzf"
 	"github.com/Zachkp/GoMail/models"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -16,6 +20,9 @@ func main() {
 		case "config":
 			handleConfigCommand()
 			return
+		case "search":
+			handleSearchCommand(os.Args[2:])
+			return
 		case "help", "-h", "--help":
 			printHelp()
 			return
@@ -30,14 +37,20 @@ func main() {
 	}
 
 	// Try to load configuration and start the TUI
-	if _, err := config.LoadConfig(); err != nil {
+	cfg, err := config.LoadConfig()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
 		fmt.Fprintf(os.Stderr, "\nTip: Run 'GoMail config' to manage your configuration\n")
 		os.Exit(1)
 	}
 
+	// Share one IMAP connection for the lifetime of the TUI
+	session := email.NewSession(cfg)
+
 	// Start the TUI
-	if _, err := tea.NewProgram(models.CreateTable(), tea.WithAltScreen()).Run(); err != nil {
+	_, err = tea.NewProgram(models.CreateTable(session), tea.WithAltScreen()).Run()
+	session.Close()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
 		os.Exit(1)
 	}
@@ -68,6 +81,7 @@ func handleConfigCommand() {
 			fmt.Printf("Username: %s\n", cfg.EmailUsername)
 			fmt.Printf("IMAP Host: %s:%s\n", cfg.EmailImapHost, cfg.EmailImapPort)
 			fmt.Printf("SMTP Host: %s:%s\n", cfg.EmailSmtpHost, cfg.EmailSmtpPort)
+			fmt.Printf("SMTP TLS: %s\n", cfg.SmtpTLS)
 		default:
 			fmt.Printf("Unknown config command: %s\n", os.Args[2])
 			printConfigHelp()
@@ -78,19 +92,110 @@ func handleConfigCommand() {
 	}
 }
 
+func handleSearchCommand(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	fs.Usage = printSearchHelp
+	field := fs.String("field", "", "limit words without a field to subject, from, to, cc or body")
+	mailbox := fs.String("mailbox", email.DefaultMailbox, "mailbox to search")
+	limit := fs.Int("limit", 100, "number of newest messages to search or show")
+	server := fs.Bool("server", false, "search the whole mailbox on the server")
+	fs.Parse(args)
+
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		printSearchHelp()
+		os.Exit(1)
+	}
+	if *limit < 1 {
+		fmt.Fprintf(os.Stderr, "Invalid -limit %d: must be at least 1\n\n", *limit)
+		printSearchHelp()
+		os.Exit(1)
+	}
+
+	// Report mistakes in the query before connecting. Gmail understands
+	// more than the parser, so server searches without -field leave it to
+	// the server; such results are listed newest first.
+	q, err := email.ParseQueryIn(query, *field)
+	if err != nil && (!*server || *field != "") {
+		fmt.Fprintf(os.Stderr, "Invalid search: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "\nTip: Run 'GoMail config' to manage your configuration\n")
+		os.Exit(1)
+	}
+
+	session := email.NewSession(cfg)
+	var results []fzf.Result
+	if *server {
+		var emails []email.Email
+		emails, _, err = session.Search(*mailbox, query, *field, uint32(*limit))
+		results = fzf.Order(emails, q)
+	} else {
+		var emails []email.Email
+		emails, err = session.FetchLatestEmails(*mailbox, uint32(*limit))
+		results = fzf.Rank(emails, q)
+	}
+	session.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Search failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No messages matched.")
+		return
+	}
+	for _, r := range results {
+		date := r.Email.Date
+		if len(date) >= 16 {
+			date = date[:16]
+		}
+		fmt.Printf("%6d  %-16s  %-30.30s  %s\n", r.Score, date, r.Email.From.Display(), r.Email.Subject)
+	}
+}
+
 func printHelp() {
 	fmt.Println(`GoMail - A terminal-based email viewer with fuzzy search
 
 Usage:
   GoMail            Start the email client
   GoMail config     Manage configuration
+  GoMail search     Search messages from the command line
   GoMail help       Show this help message
   GoMail version    Show version information
 
 For configuration management, use:
   GoMail config init      Initialize configuration
   GoMail config path      Show configuration file path
-  GoMail config validate  Validate current configuration`)
+  GoMail config validate  Validate current configuration
+
+For searching, use:
+  GoMail search -h        Show search options and query syntax`)
+}
+
+func printSearchHelp() {
+	fmt.Println(`Usage: GoMail search [options] <query>
+
+Searches the newest messages of a mailbox, best match first, the same way
+the search bar does. Message bodies are only searched with -server.
+
+Options:
+  -field name     Limit words without a field to subject, from, to, cc or body
+  -mailbox name   Mailbox to search (default INBOX)
+  -limit n        Number of newest messages to search, or to show with
+                  -server (default 100)
+  -server         Search the whole mailbox on the server
+
+Query syntax:
+  deploy                 Words are matched loosely in the sender, subject and body
+  from:alice             Limit a word to from, to, cc, subject or body
+  subject:"weekly sync"  Quote values with spaces
+  is:unread has:attachment label:work after:2026-01-31 before:2026/02/28
+  -word  a OR b  (a b)   Negate, combine and group terms`)
 }
 
 func printConfigHelp() {
@@ -0,0 +1,109 @@
+// models/attachments.go
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Zachkp/GoMail/email"
+	"github.com/Zachkp/GoMail/styles"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// AttachmentState is the attachment list of the open message
+type AttachmentState struct {
+	active bool
+	cursor int
+
+	// Directory prompt shown before saving
+	prompting bool
+	saveAll   bool
+	dirInput  textinput.Model
+}
+
+// Open the attachment list with the first attachment selected
+func OpenAttachments() AttachmentState {
+	return AttachmentState{active: true}
+}
+
+// Move the attachment cursor up
+func (a *AttachmentState) CursorUp() {
+	if a.cursor > 0 {
+		a.cursor--
+	}
+}
+
+// Move the attachment cursor down
+func (a *AttachmentState) CursorDown(count int) {
+	if a.cursor < count-1 {
+		a.cursor++
+	}
+}
+
+// Ask for the directory to save the selected attachment, or all of them, to
+func (a *AttachmentState) StartSave(all bool, dir string) {
+	ti := textinput.New()
+	ti.Prompt = "Save to: "
+	ti.CharLimit = 500
+	ti.SetValue(dir)
+	ti.CursorEnd()
+	ti.Focus()
+
+	a.dirInput = ti
+	a.prompting = true
+	a.saveAll = all
+}
+
+// Lines the attachment block takes up in the message view, including the
+// blank line that separates it from the body
+func (a AttachmentState) Height(atts []email.Attachment) int {
+	switch {
+	case len(atts) == 0:
+		return 0
+	case !a.active:
+		return 2
+	case a.prompting:
+		return len(atts) + 3
+	default:
+		return len(atts) + 2
+	}
+}
+
+// Render the attachment block shown under the message header
+func (a AttachmentState) View(atts []email.Attachment, width int) string {
+	if len(atts) == 0 {
+		return ""
+	}
+
+	// Collapsed, the attachments are summarised on one line
+	if !a.active {
+		var names []string
+		for _, att := range atts {
+			names = append(names, fmt.Sprintf("%s (%s)", att.Filename, email.FormatSize(att.Size)))
+		}
+		line := fmt.Sprintf("Attachments: %s", strings.Join(names, ", "))
+		return lipgloss.NewStyle().
+			Foreground(lipgloss.Color(styles.Green)).
+			Render(truncate(line, width))
+	}
+
+	lines := []string{lipgloss.NewStyle().Bold(true).Render("Attachments")}
+	for i, att := range atts {
+		line := fmt.Sprintf("  %s  %s  %s", att.Filename, att.MIMEType, email.FormatSize(att.Size))
+		line = truncate(line, width)
+		if i == a.cursor {
+			line = lipgloss.NewStyle().
+				Foreground(lipgloss.Color(styles.White)).
+				Background(lipgloss.Color(styles.DarkGray)).
+				Bold(true).
+				Render(line)
+		}
+		lines = append(lines, line)
+	}
+
+	if a.prompting {
+		lines = append(lines, a.dirInput.View())
+	}
+	return strings.Join(lines, "\n")
+}
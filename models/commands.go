@@ -0,0 +1,452 @@
+// models/commands.go
+package models
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Zachkp/GoMail/email"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Number of messages fetched per mailbox load
+const pageSize = 25
+
+// Sent when the mailbox list has been fetched
+type mailboxesLoadedMsg struct {
+	mailboxes []email.Mailbox
+	err       error
+}
+
+// Sent when a mailbox's messages have been fetched
+type emailsLoadedMsg struct {
+	mailbox string
+	emails  []email.Email
+	err     error
+}
+
+// Fetch the mailbox list in the background
+func loadMailboxesCmd(session *email.Session) tea.Cmd {
+	return func() tea.Msg {
+		mailboxes, err := session.ListMailboxes()
+		return mailboxesLoadedMsg{mailboxes: mailboxes, err: err}
+	}
+}
+
+// Fetch the newest messages of a mailbox in the background
+func loadEmailsCmd(session *email.Session, mailbox string) tea.Cmd {
+	return func() tea.Msg {
+		emails, err := session.FetchLatestEmails(mailbox, pageSize)
+		return emailsLoadedMsg{mailbox: mailbox, emails: emails, err: err}
+	}
+}
+
+// Sent when a page of older messages has been fetched
+type olderEmailsLoadedMsg struct {
+	mailbox string
+	emails  []email.Email
+	err     error
+}
+
+// Fetch the page of messages preceding the given UID in the background
+func loadOlderEmailsCmd(session *email.Session, mailbox string, before uint32) tea.Cmd {
+	return func() tea.Msg {
+		emails, err := session.FetchOlderEmails(mailbox, before, pageSize)
+		return olderEmailsLoadedMsg{mailbox: mailbox, emails: emails, err: err}
+	}
+}
+
+// Sent when the body of an opened message has been fetched
+type bodyLoadedMsg struct {
+	mailbox string
+	uid     uint32
+	body    string
+	err     error
+}
+
+// Fetch a message body in the background
+func loadBodyCmd(session *email.Session, mailbox string, uid uint32) tea.Cmd {
+	return func() tea.Msg {
+		body, err := session.FetchBody(mailbox, uid)
+		return bodyLoadedMsg{mailbox: mailbox, uid: uid, body: body, err: err}
+	}
+}
+
+// Sent when the loaded messages of a mailbox have been threaded
+type threadsLoadedMsg struct {
+	mailbox string
+	roots   []*email.ThreadNode
+	err     error
+}
+
+// Group the loaded messages into conversations in the background
+func loadThreadsCmd(session *email.Session, mailbox string, emails []email.Email) tea.Cmd {
+	return func() tea.Msg {
+		roots, err := session.Threads(mailbox, emails)
+		return threadsLoadedMsg{mailbox: mailbox, roots: roots, err: err}
+	}
+}
+
+// Most messages a server search fetches
+const searchLimit = 100
+
+// Sent when a server search has finished
+type searchResultsMsg struct {
+	mailbox string
+	query   string
+	field   string
+	emails  []email.Email
+	total   int
+	err     error
+}
+
+// Search the whole mailbox on the server in the background
+func serverSearchCmd(session *email.Session, mailbox, query, field string) tea.Cmd {
+	return func() tea.Msg {
+		emails, total, err := session.Search(mailbox, query, field, searchLimit)
+		return searchResultsMsg{mailbox: mailbox, query: query, field: field, emails: emails, total: total, err: err}
+	}
+}
+
+// Sent when the watcher reports new mail in a mailbox
+type newMailMsg struct {
+	watcher *email.Watcher
+	mailbox string
+}
+
+// Sent when newly arrived messages have been fetched
+type newEmailsLoadedMsg struct {
+	mailbox string
+	emails  []email.Email
+	err     error
+}
+
+// Status shown while fetching new mail fails, cleared once a fetch succeeds
+const newMailFailed = "Failed to load new messages"
+
+// Wait for the next new mail event from a watcher
+func waitForMailCmd(w *email.Watcher) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-w.Events()
+		if !ok {
+			return nil
+		}
+		return newMailMsg{watcher: w, mailbox: event.Mailbox}
+	}
+}
+
+// Fetch messages that arrived after the given UID in the background
+func loadNewEmailsCmd(session *email.Session, mailbox string, afterUID uint32) tea.Cmd {
+	return func() tea.Msg {
+		emails, err := session.FetchNewEmails(mailbox, afterUID)
+		return newEmailsLoadedMsg{mailbox: mailbox, emails: emails, err: err}
+	}
+}
+
+// Sent when an outgoing message has been submitted or has failed
+type mailSentMsg struct {
+	err error
+}
+
+// Send a message in the background
+func sendMailCmd(session *email.Session, msg *email.OutgoingMessage) tea.Cmd {
+	return func() tea.Msg {
+		return mailSentMsg{err: session.Send(msg)}
+	}
+}
+
+// Sent when the external editor exits
+type editorFinishedMsg struct {
+	msg *email.OutgoingMessage
+	err error
+}
+
+// Suspend the TUI and edit the message in $VISUAL or $EDITOR
+func editInEditorCmd(msg *email.OutgoingMessage) tea.Cmd {
+	fail := func(err error) tea.Cmd {
+		return func() tea.Msg { return editorFinishedMsg{err: err} }
+	}
+
+	f, err := os.CreateTemp("", "gomail-*.eml")
+	if err != nil {
+		return fail(fmt.Errorf("failed to create temp file: %w", err))
+	}
+	path := f.Name()
+
+	_, err = f.WriteString(msg.Template())
+	f.Close()
+	if err != nil {
+		os.Remove(path)
+		return fail(fmt.Errorf("failed to write temp file: %w", err))
+	}
+
+	editor := editorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(path)
+
+		if err != nil {
+			return editorFinishedMsg{err: fmt.Errorf("editor %s failed: %w", editor[0], err)}
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return editorFinishedMsg{err: fmt.Errorf("failed to read edited message: %w", err)}
+		}
+
+		edited, err := email.ParseTemplate(string(data), msg)
+		if err != nil {
+			return editorFinishedMsg{err: fmt.Errorf("could not parse edited message: %w", err)}
+		}
+		return editorFinishedMsg{msg: edited}
+	})
+}
+
+// Helper function to find the user's editor, which may include arguments
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// How often an open compose form is saved to the Drafts mailbox
+const draftInterval = 30 * time.Second
+
+// Sent periodically while composing to trigger a draft save
+type draftTickMsg struct {
+	gen int
+}
+
+// Sent when a draft has been saved or has failed to save
+type draftSavedMsg struct {
+	messageID string
+	err       error
+}
+
+// Sent when a saved draft has been fetched for resuming
+type draftLoadedMsg struct {
+	draft *email.OutgoingMessage
+	err   error
+}
+
+// Sent when the Drafts mailbox has been found
+type draftsMailboxMsg struct {
+	name string
+	err  error
+}
+
+// Schedule the next draft autosave
+func draftTickCmd(gen int) tea.Cmd {
+	return tea.Tick(draftInterval, func(time.Time) tea.Msg {
+		return draftTickMsg{gen: gen}
+	})
+}
+
+// Save a draft in the background
+func saveDraftCmd(session *email.Session, msg *email.OutgoingMessage) tea.Cmd {
+	return func() tea.Msg {
+		return draftSavedMsg{messageID: msg.MessageID, err: session.SaveDraft(msg)}
+	}
+}
+
+// Delete a draft in the background once its message has been sent
+func deleteDraftCmd(session *email.Session, messageID string) tea.Cmd {
+	return func() tea.Msg {
+		if err := session.DeleteDraft(messageID); err != nil {
+			return statusMsg(fmt.Sprintf("Message sent, but the draft could not be deleted: %v", err))
+		}
+		return nil
+	}
+}
+
+// Fetch a saved draft in the background
+func loadDraftCmd(session *email.Session, mailbox string, uid uint32) tea.Cmd {
+	return func() tea.Msg {
+		draft, err := session.FetchDraft(mailbox, uid)
+		return draftLoadedMsg{draft: draft, err: err}
+	}
+}
+
+// Sent when a forward has been prepared along with the original's attachments
+type forwardReadyMsg struct {
+	forward *email.OutgoingMessage
+	err     error
+}
+
+// Prepare a forward in the background, downloading the attachments of the
+// original so they are sent on with it
+func forwardCmd(session *email.Session, mailbox string, original email.Email) tea.Cmd {
+	return func() tea.Msg {
+		dir, err := session.TempDir()
+		if err != nil {
+			return forwardReadyMsg{err: err}
+		}
+		paths, err := session.SaveAttachments(mailbox, original.UID, original.Attachments, dir)
+		if err != nil {
+			return forwardReadyMsg{err: err}
+		}
+
+		forward := email.Forward(original)
+		forward.Attachments = paths
+		return forwardReadyMsg{forward: forward}
+	}
+}
+
+// Find the Drafts mailbox in the background
+func draftsMailboxCmd(session *email.Session) tea.Cmd {
+	return func() tea.Msg {
+		name, err := session.DraftsMailbox()
+		return draftsMailboxMsg{name: name, err: err}
+	}
+}
+
+// Sent by background work that only needs to report something to the user
+type statusMsg string
+
+// Sent when a flag change has been stored on the server
+type flagsUpdatedMsg struct {
+	mailbox string
+	uids    []uint32
+	flag    string
+	on      bool
+	err     error
+}
+
+// Add or remove a flag on the server in the background
+func setFlagCmd(session *email.Session, mailbox string, uids []uint32, flag string, on bool) tea.Cmd {
+	return func() tea.Msg {
+		err := session.SetFlag(mailbox, uids, flag, on)
+		return flagsUpdatedMsg{mailbox: mailbox, uids: uids, flag: flag, on: on, err: err}
+	}
+}
+
+// Sent when the flags of loaded messages have been fetched again
+type flagsSyncedMsg struct {
+	mailbox string
+	uids    []uint32
+	flags   map[uint32][]string
+	err     error
+}
+
+// Fetch the server's flags for loaded messages in the background
+func syncFlagsCmd(session *email.Session, mailbox string, uids []uint32) tea.Cmd {
+	return func() tea.Msg {
+		flags, err := session.FetchFlags(mailbox, uids)
+		return flagsSyncedMsg{mailbox: mailbox, uids: uids, flags: flags, err: err}
+	}
+}
+
+// Sent when attachments have been saved
+type attachmentsSavedMsg struct {
+	mailbox string
+	dir     string
+	paths   []string
+	err     error
+}
+
+// Save attachments of a message to a directory in the background
+func saveAttachmentsCmd(session *email.Session, mailbox string, uid uint32, atts []email.Attachment, dir string) tea.Cmd {
+	return func() tea.Msg {
+		paths, err := session.SaveAttachments(mailbox, uid, atts, dir)
+		return attachmentsSavedMsg{mailbox: mailbox, dir: dir, paths: paths, err: err}
+	}
+}
+
+// Download an attachment to a temporary directory and hand it to the
+// configured opener without waiting for it to exit. Openers often return
+// before the viewer has read the file, so it is left in place until the
+// session closes.
+func openAttachmentCmd(session *email.Session, mailbox string, uid uint32, att email.Attachment) tea.Cmd {
+	return func() tea.Msg {
+		dir, err := session.TempDir()
+		if err != nil {
+			return statusMsg(fmt.Sprintf("Failed to open %s: %v", att.Filename, err))
+		}
+
+		paths, err := session.SaveAttachments(mailbox, uid, []email.Attachment{att}, dir)
+		if err != nil {
+			os.RemoveAll(dir)
+			return statusMsg(fmt.Sprintf("Failed to open %s: %v", att.Filename, err))
+		}
+
+		opener := openCommand(session.Config().OpenCommand)
+		cmd := exec.Command(opener[0], append(opener[1:], paths[0])...)
+		if err := cmd.Start(); err != nil {
+			os.RemoveAll(dir)
+			return statusMsg(fmt.Sprintf("Failed to run %s: %v", opener[0], err))
+		}
+
+		// Reap the opener so it does not linger as a zombie
+		go cmd.Wait()
+		return statusMsg(fmt.Sprintf("Opened %s", att.Filename))
+	}
+}
+
+// Helper function to find the program that opens attachments, which may
+// include arguments
+func openCommand(configured string) []string {
+	if fields := strings.Fields(configured); len(fields) > 0 {
+		return fields
+	}
+	if runtime.GOOS == "darwin" {
+		return []string{"open"}
+	}
+	return []string{"xdg-open"}
+}
+
+// Sent when messages have been deleted, archived or moved
+type messagesMovedMsg struct {
+	mailbox string
+	uids    []uint32
+	verb    string
+	done    string
+	err     error
+}
+
+// Sent once it is known whether deleting messages removes them for good
+type deleteCheckedMsg struct {
+	mailbox string
+	uids    []uint32
+	forGood bool
+	err     error
+}
+
+// Find out in the background whether deleting messages needs confirming
+func checkDeleteCmd(session *email.Session, mailbox string, uids []uint32) tea.Cmd {
+	return func() tea.Msg {
+		forGood, err := session.DeletesForGood(mailbox)
+		return deleteCheckedMsg{mailbox: mailbox, uids: uids, forGood: forGood, err: err}
+	}
+}
+
+// Delete messages in the background
+func deleteCmd(session *email.Session, mailbox string, uids []uint32) tea.Cmd {
+	return func() tea.Msg {
+		err := session.Delete(mailbox, uids)
+		return messagesMovedMsg{mailbox: mailbox, uids: uids, verb: "delete", done: "Deleted", err: err}
+	}
+}
+
+// Archive messages in the background
+func archiveCmd(session *email.Session, mailbox string, uids []uint32) tea.Cmd {
+	return func() tea.Msg {
+		err := session.Archive(mailbox, uids)
+		return messagesMovedMsg{mailbox: mailbox, uids: uids, verb: "archive", done: "Archived", err: err}
+	}
+}
+
+// Move messages to another mailbox in the background
+func moveCmd(session *email.Session, mailbox string, uids []uint32, dest string) tea.Cmd {
+	return func() tea.Msg {
+		err := session.Move(mailbox, uids, dest)
+		return messagesMovedMsg{mailbox: mailbox, uids: uids, verb: "move", done: "Moved to " + dest, err: err}
+	}
+}
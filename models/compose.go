@@ -0,0 +1,249 @@
+// models/compose.go
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Zachkp/GoMail/email"
+	"github.com/Zachkp/GoMail/styles"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Compose fields in tab order
+const (
+	fieldTo = iota
+	fieldCc
+	fieldBcc
+	fieldSubject
+	fieldBody
+	fieldCount
+)
+
+var fieldLabels = []string{"To", "Cc", "Bcc", "Subject"}
+
+// ComposeState holds the message being written
+type ComposeState struct {
+	inputs  []textinput.Model
+	body    textarea.Model
+	focus   int
+	sending bool
+	err     error
+
+	// Carried over from the message being replied to or forwarded
+	inReplyTo  string
+	references []string
+
+	// Local files to attach; attachWarned is set once the user has been told
+	// the body mentions an attachment that is missing
+	attachments  []string
+	attachWarned bool
+
+	// Draft autosave state; the Message-ID stays the same for every version
+	messageID   string
+	lastSaved   string
+	hasDraft    bool
+	savingDraft bool
+	pendingSave string
+	draftStatus string
+}
+
+// Initialize an empty compose form
+func InitCompose() ComposeState {
+	inputs := make([]textinput.Model, len(fieldLabels))
+	for i := range inputs {
+		ti := textinput.New()
+		ti.Prompt = ""
+		ti.CharLimit = 1000
+		inputs[i] = ti
+	}
+	inputs[fieldTo].Placeholder = "alice@example.com, Bob <bob@example.com>"
+
+	body := textarea.New()
+	body.Placeholder = "Write your message..."
+	body.ShowLineNumbers = false
+	body.CharLimit = 0
+
+	c := ComposeState{
+		inputs:    inputs,
+		body:      body,
+		messageID: email.GenerateMessageID(),
+	}
+	c.setFocus(fieldTo)
+
+	return c
+}
+
+// Initialize a compose form prefilled from a reply or forward
+func InitComposeFrom(msg *email.OutgoingMessage) ComposeState {
+	c := InitCompose()
+	c.inputs[fieldTo].SetValue(strings.Join(msg.To, ", "))
+	c.inputs[fieldCc].SetValue(strings.Join(msg.Cc, ", "))
+	c.inputs[fieldBcc].SetValue(strings.Join(msg.Bcc, ", "))
+	c.inputs[fieldSubject].SetValue(msg.Subject)
+	c.body.SetValue(msg.Body)
+	c.inReplyTo = msg.InReplyTo
+	c.references = msg.References
+	c.attachments = msg.Attachments
+	if msg.MessageID != "" {
+		c.messageID = msg.MessageID
+	}
+
+	// Replies already know their recipients, so start in the body
+	if len(msg.To) > 0 {
+		c.setFocus(fieldBody)
+		for c.body.Line() > 0 {
+			c.body.CursorUp()
+		}
+		c.body.CursorStart()
+	}
+
+	return c
+}
+
+// Resize the form to fit the terminal
+func (c *ComposeState) SetSize(width, height int) {
+	for i := range c.inputs {
+		c.inputs[i].Width = width - 14
+	}
+	c.body.SetWidth(width - 4)
+
+	// Leave room for the inputs, the attachment line and the status lines
+	bodyHeight := height - len(c.inputs) - 9
+	if bodyHeight < 3 {
+		bodyHeight = 3
+	}
+	c.body.SetHeight(bodyHeight)
+}
+
+// Move focus to the next field
+func (c *ComposeState) NextField() {
+	c.setFocus((c.focus + 1) % fieldCount)
+}
+
+// Move focus to the previous field
+func (c *ComposeState) PrevField() {
+	c.setFocus((c.focus + fieldCount - 1) % fieldCount)
+}
+
+func (c *ComposeState) setFocus(field int) {
+	c.focus = field
+	for i := range c.inputs {
+		if i == field {
+			c.inputs[i].Focus()
+		} else {
+			c.inputs[i].Blur()
+		}
+	}
+	if field == fieldBody {
+		c.body.Focus()
+	} else {
+		c.body.Blur()
+	}
+}
+
+// Pass input to the focused field
+func (c *ComposeState) Update(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	if c.focus == fieldBody {
+		c.body, cmd = c.body.Update(msg)
+	} else {
+		c.inputs[c.focus], cmd = c.inputs[c.focus].Update(msg)
+	}
+	return cmd
+}
+
+// Build the outgoing message from the form
+func (c *ComposeState) Message() *email.OutgoingMessage {
+	return &email.OutgoingMessage{
+		To:      email.SplitAddresses(c.inputs[fieldTo].Value()),
+		Cc:      email.SplitAddresses(c.inputs[fieldCc].Value()),
+		Bcc:     email.SplitAddresses(c.inputs[fieldBcc].Value()),
+		Subject: c.inputs[fieldSubject].Value(),
+		Body:    c.body.Value(),
+
+		InReplyTo:   c.inReplyTo,
+		References:  c.references,
+		MessageID:   c.messageID,
+		Attachments: c.attachments,
+	}
+}
+
+// Add a file to the message unless it is already attached
+func (c *ComposeState) Attach(path string) {
+	for _, p := range c.attachments {
+		if p == path {
+			return
+		}
+	}
+	c.attachments = append(c.attachments, path)
+	c.attachWarned = false
+}
+
+// Remove the most recently attached file
+func (c *ComposeState) DetachLast() {
+	if n := len(c.attachments); n > 0 {
+		c.attachments = c.attachments[:n-1]
+	}
+}
+
+// Report whether the form changed since it was opened or last saved
+func (c *ComposeState) Dirty() bool {
+	return c.Message().Template() != c.lastSaved
+}
+
+// Render compose form
+func (c *ComposeState) View(width, height int, spinnerView string) string {
+	labelStyle := lipgloss.NewStyle().Width(10).Bold(true)
+	focusedLabel := labelStyle.Foreground(lipgloss.Color(styles.Green))
+
+	var lines []string
+	for i, input := range c.inputs {
+		label := labelStyle
+		if i == c.focus {
+			label = focusedLabel
+		}
+		lines = append(lines, label.Render(fieldLabels[i]+":")+input.View())
+	}
+
+	attached := lipgloss.NewStyle().
+		Foreground(lipgloss.Color(styles.DarkGray)).
+		Render(fmt.Sprintf("none (%s to add)", ComposeKeys.Attach.Help().Key))
+	if len(c.attachments) > 0 {
+		var names []string
+		for _, path := range c.attachments {
+			names = append(names, filepath.Base(path))
+		}
+		attached = truncate(strings.Join(names, ", "), width-30)
+	}
+	lines = append(lines, labelStyle.Render("Attach:")+attached)
+
+	lines = append(lines, "", c.body.View(), "")
+
+	if c.draftStatus != "" {
+		lines = append(lines, lipgloss.NewStyle().
+			Foreground(lipgloss.Color(styles.DarkGray)).
+			Render(c.draftStatus))
+	}
+
+	switch {
+	case c.sending:
+		lines = append(lines, fmt.Sprintf("%s Sending...", spinnerView))
+	case c.err != nil:
+		lines = append(lines, lipgloss.NewStyle().
+			Foreground(lipgloss.Color(styles.Red)).
+			Render(c.err.Error()))
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color(styles.Green)).
+		Padding(1, 2).
+		Width(width - 8).
+		Height(height - 6).
+		Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,128 @@
+// models/filepicker.go
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Zachkp/GoMail/email"
+	"github.com/Zachkp/GoMail/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// FilePickerState is a fuzzy file picker for choosing attachments. The input
+// holds a path: the entries of its directory part are fuzzy matched against
+// whatever follows the last slash.
+type FilePickerState struct {
+	FuzzyList
+	active  bool
+	dir     string
+	entries []string
+	err     error
+}
+
+// Open the file picker in the given directory
+func OpenFilePicker(dir string) FilePickerState {
+	p := FilePickerState{
+		FuzzyList: NewFuzzyList("Attach file...", 500),
+		active:    true,
+	}
+	p.input.SetValue(strings.TrimSuffix(dir, "/") + "/")
+	p.input.CursorEnd()
+	p.filter()
+
+	return p
+}
+
+// Refilter the files after the path changed, listing a new directory if
+// the directory part changed
+func (p *FilePickerState) filter() {
+	value := p.input.Value()
+	var dir, query string
+	if i := strings.LastIndex(value, "/"); i >= 0 {
+		dir, query = value[:i+1], value[i+1:]
+	} else {
+		dir, query = "./", value
+	}
+
+	if dir != p.dir {
+		p.dir = dir
+		p.entries, p.err = listDir(dir)
+	}
+
+	// Hidden files only show up once the query asks for them
+	var choices []string
+	for _, name := range p.entries {
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(query, ".") {
+			continue
+		}
+		choices = append(choices, name)
+	}
+
+	p.match(query, choices)
+}
+
+// Helper function to list a directory, marking subdirectories with a
+// trailing slash
+func listDir(dir string) ([]string, error) {
+	path, err := email.ExpandHome(strings.TrimSuffix(dir, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Choose the entry under the cursor. Directories are entered and reported
+// with an empty path; files are returned as an absolute path.
+func (p *FilePickerState) Choose() (string, error) {
+	name := p.Selected()
+	if name == "" {
+		return "", nil
+	}
+
+	if strings.HasSuffix(name, "/") {
+		p.input.SetValue(p.dir + name)
+		p.input.CursorEnd()
+		p.cursor = 0
+		p.filter()
+		return "", nil
+	}
+
+	path, err := email.ExpandHome(p.dir + name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// Render file picker
+func (p *FilePickerState) View(width int) string {
+	note := ""
+	switch {
+	case p.err != nil:
+		note = lipgloss.NewStyle().
+			Foreground(lipgloss.Color(styles.Red)).
+			Render(p.err.Error())
+	case len(p.matches) == 0:
+		note = "No matching files"
+	}
+	return p.FuzzyList.View(width, note)
+}
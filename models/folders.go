@@ -0,0 +1,134 @@
+// models/folders.go
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Zachkp/GoMail/email"
+	"github.com/Zachkp/GoMail/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Width of the folder sidebar including its border
+const sidebarWidth = 28
+
+// FolderState tracks the mailbox sidebar
+type FolderState struct {
+	focused   bool
+	mailboxes []email.Mailbox
+	cursor    int
+	current   string
+}
+
+// Initialize folder sidebar
+func InitFolders(mailboxes []email.Mailbox) FolderState {
+	return FolderState{
+		mailboxes: mailboxes,
+		current:   email.DefaultMailbox,
+	}
+}
+
+// Move the sidebar cursor up
+func (f *FolderState) CursorUp() {
+	if f.cursor > 0 {
+		f.cursor--
+	}
+}
+
+// Move the sidebar cursor down
+func (f *FolderState) CursorDown() {
+	if f.cursor < len(f.mailboxes)-1 {
+		f.cursor++
+	}
+}
+
+// Mailbox under the cursor, empty if there are none
+func (f *FolderState) Highlighted() string {
+	if f.cursor >= 0 && f.cursor < len(f.mailboxes) {
+		return f.mailboxes[f.cursor].Name
+	}
+	return ""
+}
+
+// Bump the message and unread counts shown for a mailbox when messages
+// arrive, unseen of them unread
+func (f *FolderState) AddArrivals(name string, n, unseen int) {
+	for i := range f.mailboxes {
+		if f.mailboxes[i].Name == name {
+			f.mailboxes[i].Messages += uint32(n)
+			f.mailboxes[i].Unseen += uint32(unseen)
+		}
+	}
+}
+
+// Change the unread count shown for a mailbox
+func (f *FolderState) AdjustUnseen(name string, delta int) {
+	for i := range f.mailboxes {
+		if f.mailboxes[i].Name == name {
+			unseen := int(f.mailboxes[i].Unseen) + delta
+			if unseen < 0 {
+				unseen = 0
+			}
+			f.mailboxes[i].Unseen = uint32(unseen)
+		}
+	}
+}
+
+// Render folder sidebar
+func (f *FolderState) RenderSidebar(height int) string {
+	var lines []string
+
+	for i, mb := range f.mailboxes {
+		name := mb.Name
+		if mb.Unseen > 0 {
+			name = fmt.Sprintf("%s (%d)", name, mb.Unseen)
+		}
+
+		prefix := "  "
+		if mb.Name == f.current {
+			prefix = "> "
+		}
+
+		line := truncate(prefix+name, sidebarWidth-4)
+		style := lipgloss.NewStyle()
+		if f.focused && i == f.cursor {
+			style = style.
+				Foreground(lipgloss.Color(styles.White)).
+				Background(lipgloss.Color(styles.DarkGray)).
+				Bold(true)
+		} else if mb.Name == f.current {
+			style = style.Foreground(lipgloss.Color(styles.Green))
+		}
+
+		lines = append(lines, style.Render(line))
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "No folders")
+	}
+
+	borderColor := styles.DarkGray
+	if f.focused {
+		borderColor = styles.Green
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color(borderColor)).
+		Width(sidebarWidth - 2).
+		Height(height).
+		Render(strings.Join(lines, "\n"))
+}
+
+// Helper function to cut a string down to a maximum display width
+func truncate(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
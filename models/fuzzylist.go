@@ -0,0 +1,137 @@
+// models/fuzzylist.go
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Zachkp/GoMail/fzf"
+	"github.com/Zachkp/GoMail/styles"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Number of matches shown at once
+const pickerRows = 10
+
+// Style of the match under the cursor
+var pickerSelectedStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color(styles.White)).
+	Background(lipgloss.Color(styles.DarkGray)).
+	Bold(true)
+
+// FuzzyList is the filter input and scrolling list of matches shared by the
+// folder and file pickers. Choices are matched and highlighted with the same
+// scoring as message search.
+type FuzzyList struct {
+	input   textinput.Model
+	query   string
+	matches []string
+	cursor  int
+}
+
+// Create a list with a focused, empty filter input
+func NewFuzzyList(placeholder string, charLimit int) FuzzyList {
+	ti := textinput.New()
+	ti.Placeholder = placeholder
+	ti.CharLimit = charLimit
+	ti.Focus()
+	return FuzzyList{input: ti}
+}
+
+// Match the choices against a query, best match first, keeping the cursor
+// on the list. An empty query matches everything in order.
+func (l *FuzzyList) match(query string, choices []string) {
+	l.query = query
+	if query == "" {
+		l.matches = choices
+	} else {
+		type scored struct {
+			choice string
+			score  int
+		}
+		var ranked []scored
+		for _, c := range choices {
+			if score, _, ok := fzf.Match(query, c); ok {
+				ranked = append(ranked, scored{choice: c, score: score})
+			}
+		}
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return ranked[i].score > ranked[j].score
+		})
+
+		l.matches = nil
+		for _, r := range ranked {
+			l.matches = append(l.matches, r.choice)
+		}
+	}
+
+	if l.cursor >= len(l.matches) {
+		l.cursor = len(l.matches) - 1
+	}
+	if l.cursor < 0 {
+		l.cursor = 0
+	}
+}
+
+// Move the cursor up
+func (l *FuzzyList) CursorUp() {
+	if l.cursor > 0 {
+		l.cursor--
+	}
+}
+
+// Move the cursor down
+func (l *FuzzyList) CursorDown() {
+	if l.cursor < len(l.matches)-1 {
+		l.cursor++
+	}
+}
+
+// Match under the cursor, empty if nothing matches
+func (l *FuzzyList) Selected() string {
+	if l.cursor >= 0 && l.cursor < len(l.matches) {
+		return l.matches[l.cursor]
+	}
+	return ""
+}
+
+// Render the input above the matches in view, followed by a note such as
+// why nothing matched
+func (l *FuzzyList) View(width int, note string) string {
+	lines := []string{l.input.View(), ""}
+
+	// Scroll the list so the cursor stays visible
+	start := 0
+	if l.cursor >= pickerRows {
+		start = l.cursor - pickerRows + 1
+	}
+	end := start + pickerRows
+	if end > len(l.matches) {
+		end = len(l.matches)
+	}
+
+	var patterns []string
+	if l.query != "" {
+		patterns = []string{l.query}
+	}
+	for i := start; i < end; i++ {
+		positions := fzf.Positions(patterns, l.matches[i])
+		line := "  " + renderMatches(l.matches[i], positions, lipgloss.NewStyle())
+		if i == l.cursor {
+			line = pickerSelectedStyle.Render("> ") +
+				renderMatches(l.matches[i], positions, pickerSelectedStyle)
+		}
+		lines = append(lines, line)
+	}
+	if note != "" {
+		lines = append(lines, "  "+note)
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(styles.Green)).
+		Padding(0, 1).
+		Width(width).
+		Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,97 @@
+// models/header.go
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Zachkp/GoMail/email"
+	"github.com/Zachkp/GoMail/fzf"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Recipient lists longer than this are cut short until expanded
+const collapsedRecipients = 3
+
+// Helper function to format the sender column of the message list
+func senderColumn(from email.Address, nameOnly bool) string {
+	if nameOnly {
+		return from.Display()
+	}
+	return from.String()
+}
+
+// Helper function to format a recipient list, cutting long lists short
+// unless expanded
+func recipientList(addrs []email.Address, expanded bool) string {
+	if expanded || len(addrs) <= collapsedRecipients {
+		return email.FormatAddresses(addrs)
+	}
+	return fmt.Sprintf("%s and %d more (%s to show all)",
+		email.FormatAddresses(addrs[:collapsedRecipients]),
+		len(addrs)-collapsedRecipients,
+		CommonKeys.Recipients.Help().Key)
+}
+
+// Helper function to render the header block of the open message, with
+// the words of the search returned by highlights picked out
+func emailHeaderView(e email.Email, width int, expanded bool, highlights func(field string) []string) string {
+	bold := lipgloss.NewStyle().Bold(true)
+
+	var lines []string
+	field := func(label, value string) {
+		line := label + ": " + value
+		if !expanded {
+			line = truncate(line, width)
+		}
+		if words := highlights(strings.ToLower(label)); len(words) > 0 {
+			value = strings.TrimPrefix(line, label+": ")
+			positions := fzf.Positions(words, strings.TrimSuffix(value, "…"))
+			line = bold.Render(label+": ") + renderMatches(value, positions, bold)
+		}
+		lines = append(lines, line)
+	}
+
+	field("From", e.From.String())
+	if e.Sender.Addr != "" {
+		field("Sender", e.Sender.String())
+	}
+	if len(e.To) > 0 {
+		field("To", recipientList(e.To, expanded))
+	}
+	if len(e.Cc) > 0 {
+		field("Cc", recipientList(e.Cc, expanded))
+	}
+	// Reply-To is usually just the sender again
+	if len(e.ReplyTo) > 0 && !(len(e.ReplyTo) == 1 && e.ReplyTo[0].Equal(e.From)) {
+		field("Reply-To", recipientList(e.ReplyTo, expanded))
+	}
+
+	datePart, timePart := e.Date, ""
+	if len(e.Date) >= 10 {
+		datePart = e.Date[:10]
+	}
+	if len(e.Date) >= 16 {
+		timePart = e.Date[11:16]
+	}
+	field("Date", datePart)
+	field("Time", timePart)
+	field("Subject", e.Subject)
+
+	return lipgloss.NewStyle().
+		Bold(true).
+		Width(width).
+		Padding(0, 0, 1, 0).
+		Render(strings.Join(lines, "\n"))
+}
+
+// Helper function to report whether the open message has recipient lists
+// long enough to expand
+func hasLongRecipients(e email.Email) bool {
+	for _, list := range [][]email.Address{e.To, e.Cc, e.ReplyTo} {
+		if len(list) > collapsedRecipients {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+// models/highlight.go
+package models
+
+import (
+	"strings"
+
+	"github.com/Zachkp/GoMail/fzf"
+	"github.com/Zachkp/GoMail/styles"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Style of the characters a search matched
+var matchStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color(styles.Green)).
+	Bold(true).
+	Underline(true)
+
+// Helper function to render text with the characters at positions in the
+// match style and the rest in base
+func renderMatches(text string, positions []int, base lipgloss.Style) string {
+	if len(positions) == 0 {
+		return base.Render(text)
+	}
+
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+	match := matchStyle.Inherit(base)
+
+	var b strings.Builder
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && matched[end] == matched[start] {
+			end++
+		}
+		style := base
+		if matched[start] {
+			style = match
+		}
+		b.WriteString(style.Render(string(runes[start:end])))
+		start = end
+	}
+	return b.String()
+}
+
+// Helper function to highlight search words in text, line by line so
+// styles do not run across lines
+func highlightText(text string, patterns []string) string {
+	if len(patterns) == 0 {
+		return text
+	}
+	positions := fzf.Positions(patterns, text)
+
+	lines := strings.Split(text, "\n")
+	offset := 0
+	for i, line := range lines {
+		n := len([]rune(line))
+		var local []int
+		for len(positions) > 0 && positions[0] < offset+n {
+			if positions[0] >= offset {
+				local = append(local, positions[0]-offset)
+			}
+			positions = positions[1:]
+		}
+		if len(local) > 0 {
+			lines[i] = renderMatches(line, local, lipgloss.NewStyle())
+		}
+		offset += n + 1
+	}
+	return strings.Join(lines, "\n")
+}
+
+// highlightTable highlights search words in the sender and subject columns
+// of the rendered table. The table measures cells without regard to escape
+// codes, so the highlights cannot be part of the rows themselves; instead
+// each row is redrawn from its plain text, keeping the selected row's style.
+func highlightTable(t table.Model, selected lipgloss.Style, sender, subject []string) string {
+	view := t.View()
+	if len(sender) == 0 && len(subject) == 0 {
+		return view
+	}
+	cols := t.Columns()
+
+	// Find where the columns start; every cell has a space either side
+	patterns := make([][]string, len(cols))
+	patterns[senderCol] = sender
+	patterns[subjectCol] = subject
+	starts := make([]int, len(cols))
+	offset := 0
+	for i, c := range cols {
+		if c.Width <= 0 {
+			// The table leaves out columns without room
+			patterns[i] = nil
+			continue
+		}
+		starts[i] = offset + 1
+		offset += c.Width + 2
+	}
+
+	// The rows fill the table's height below the header
+	lines := strings.Split(view, "\n")
+	for i := max(len(lines)-t.Height(), 0); i < len(lines); i++ {
+		plain := ansi.Strip(lines[i])
+		if strings.TrimSpace(plain) == "" {
+			continue
+		}
+		base := lipgloss.NewStyle()
+		if plain != lines[i] {
+			base = selected
+		}
+
+		var b strings.Builder
+		pos := 0
+		for col, words := range patterns {
+			if len(words) == 0 {
+				continue
+			}
+			end := starts[col] + cols[col].Width
+			b.WriteString(base.Render(ansi.Cut(plain, pos, starts[col])))
+
+			cell := ansi.Cut(plain, starts[col], end)
+			text := strings.TrimSuffix(strings.TrimRight(cell, " "), "…")
+			b.WriteString(renderMatches(cell, fzf.Positions(words, text), base))
+			pos = end
+		}
+		b.WriteString(base.Render(ansi.Cut(plain, pos, ansi.StringWidth(plain))))
+		lines[i] = b.String()
+	}
+	return strings.Join(lines, "\n")
+}
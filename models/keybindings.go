@@ -7,48 +7,231 @@ import (
 
 // Shared keymap and help instance
 var (
-	CommonKeys = NewKeyMap()
-	CommonHelp = help.New()
+	CommonKeys     = NewKeyMap()
+	ComposeKeys    = NewComposeKeyMap()
+	AttachmentKeys = NewAttachmentKeyMap()
+	SearchKeys     = NewSearchKeyMap()
+	ConfirmKeys    = NewConfirmKeyMap()
+	CommonHelp     = help.New()
 )
 
 type KeyMap struct {
-	Up       key.Binding
-	Down     key.Binding
-	PageUp   key.Binding
-	PageDown key.Binding
-	Back     key.Binding
-	Select   key.Binding
-	Search   key.Binding
-	Quit     key.Binding
+	Up          key.Binding
+	Down        key.Binding
+	PageUp      key.Binding
+	PageDown    key.Binding
+	Back        key.Binding
+	Select      key.Binding
+	Search      key.Binding
+	Folders     key.Binding
+	LoadMore    key.Binding
+	Compose     key.Binding
+	Drafts      key.Binding
+	ToggleRead  key.Binding
+	Delete      key.Binding
+	Archive     key.Binding
+	Move        key.Binding
+	Flag        key.Binding
+	Flagged     key.Binding
+	Threads     key.Binding
+	Fold        key.Binding
+	Refresh     key.Binding
+	Mark        key.Binding
+	Visual      key.Binding
+	Unmark      key.Binding
+	Reply       key.Binding
+	ReplyAll    key.Binding
+	Forward     key.Binding
+	Attachments key.Binding
+	Recipients  key.Binding
+	Retry       key.Binding
+	Help        key.Binding
+	Quit        key.Binding
 }
 
+// The short help keeps to the everyday keys; the rest are behind ?
 func (k KeyMap) ShortHelp() []key.Binding {
 	return []key.Binding{
 		k.Up,
 		k.Down,
-		k.PageUp,
-		k.PageDown,
 		k.Select,
+		k.Back,
 		k.Search,
+		k.Compose,
+		k.Reply,
+		k.Delete,
+		k.Retry,
+		k.Help,
 		k.Quit,
-		k.Back,
 	}
 }
 
 func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Up, k.Down, k.PageUp, k.PageDown, k.Select},
-		{k.Search, k.Quit, k.Back},
+		{k.Up, k.Down, k.PageUp, k.PageDown, k.Select, k.Back},
+		{k.Reply, k.ReplyAll, k.Forward, k.Attachments, k.Recipients},
+		{k.ToggleRead, k.Flag, k.Flagged, k.Delete, k.Archive},
+		{k.Move, k.Mark, k.Visual, k.Unmark},
+		{k.Threads, k.Fold, k.Search, k.Folders},
+		{k.LoadMore, k.Refresh, k.Retry, k.Compose, k.Drafts},
+		{k.Help, k.Quit},
 	}
 }
 
 func NewKeyMap() KeyMap {
 	return KeyMap{
-		Up:     key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("↑ - k", "up")),
-		Down:   key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("↓ - j", "down")),
-		Back:   key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "back")),
-		Select: key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "select")),
-		Search: key.NewBinding(key.WithKeys("/", "f"), key.WithHelp("/ - f", "search")),
-		Quit:   key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit")),
+		Up:          key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("↑ - k", "up")),
+		Down:        key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("↓ - j", "down")),
+		Back:        key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "back")),
+		Select:      key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "select")),
+		Search:      key.NewBinding(key.WithKeys("/", "f"), key.WithHelp("/ - f", "search")),
+		Folders:     key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "folders")),
+		LoadMore:    key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "load more")),
+		Compose:     key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "compose")),
+		Drafts:      key.NewBinding(key.WithKeys("D"), key.WithHelp("D", "drafts")),
+		ToggleRead:  key.NewBinding(key.WithKeys("u"), key.WithHelp("u", "read/unread")),
+		Delete:      key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "delete")),
+		Archive:     key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "archive")),
+		Move:        key.NewBinding(key.WithKeys("M"), key.WithHelp("M", "move")),
+		Flag:        key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "flag")),
+		Flagged:     key.NewBinding(key.WithKeys("*"), key.WithHelp("*", "flagged only")),
+		Threads:     key.NewBinding(key.WithKeys("T"), key.WithHelp("T", "threads")),
+		Fold:        key.NewBinding(key.WithKeys("z"), key.WithHelp("z", "fold thread")),
+		Refresh:     key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "refresh")),
+		Mark:        key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "mark")),
+		Visual:      key.NewBinding(key.WithKeys("V"), key.WithHelp("V", "visual select")),
+		Unmark:      key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "clear marks")),
+		Reply:       key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "reply")),
+		ReplyAll:    key.NewBinding(key.WithKeys("R"), key.WithHelp("R", "reply all")),
+		Forward:     key.NewBinding(key.WithKeys("F"), key.WithHelp("F", "forward")),
+		Attachments: key.NewBinding(key.WithKeys("A"), key.WithHelp("A", "attachments")),
+		Recipients:  key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "all recipients")),
+		Retry:       key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "retry")),
+		Help:        key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "more keys")),
+		Quit:        key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit")),
+	}
+}
+
+// Keys used while writing a message, where letters must reach the inputs
+type ComposeKeyMap struct {
+	NextField key.Binding
+	PrevField key.Binding
+	Attach    key.Binding
+	Detach    key.Binding
+	Editor    key.Binding
+	Send      key.Binding
+	Cancel    key.Binding
+	Quit      key.Binding
+}
+
+func (k ComposeKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.NextField, k.PrevField, k.Attach, k.Detach, k.Editor, k.Send, k.Cancel, k.Quit}
+}
+
+func (k ComposeKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.NextField, k.PrevField, k.Editor},
+		{k.Attach, k.Detach},
+		{k.Send, k.Cancel, k.Quit},
+	}
+}
+
+func NewComposeKeyMap() ComposeKeyMap {
+	return ComposeKeyMap{
+		NextField: key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next field")),
+		PrevField: key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", "prev field")),
+		Attach:    key.NewBinding(key.WithKeys("ctrl+o"), key.WithHelp("ctrl+o", "attach")),
+		Detach:    key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("ctrl+x", "remove attachment")),
+		Editor:    key.NewBinding(key.WithKeys("ctrl+e"), key.WithHelp("ctrl+e", "$EDITOR")),
+		Send:      key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "send")),
+		Cancel:    key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "cancel")),
+		Quit:      key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit")),
+	}
+}
+
+// Keys used in the attachment list of the open message
+type AttachmentKeyMap struct {
+	Up      key.Binding
+	Down    key.Binding
+	Open    key.Binding
+	Save    key.Binding
+	SaveAll key.Binding
+	Close   key.Binding
+	Quit    key.Binding
+}
+
+func (k AttachmentKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Up, k.Down, k.Open, k.Save, k.SaveAll, k.Close, k.Quit}
+}
+
+func (k AttachmentKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Up, k.Down, k.Open},
+		{k.Save, k.SaveAll, k.Close, k.Quit},
+	}
+}
+
+func NewAttachmentKeyMap() AttachmentKeyMap {
+	return AttachmentKeyMap{
+		Up:      key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("↑ - k", "up")),
+		Down:    key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("↓ - j", "down")),
+		Open:    key.NewBinding(key.WithKeys("o", "enter"), key.WithHelp("o", "open")),
+		Save:    key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "save")),
+		SaveAll: key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "save all")),
+		Close:   key.NewBinding(key.WithKeys("esc", "A"), key.WithHelp("esc", "close")),
+		Quit:    key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit")),
+	}
+}
+
+// Keys used while typing in the search bar, where letters must reach the input
+type SearchKeyMap struct {
+	Mode   key.Binding
+	Field  key.Binding
+	Run    key.Binding
+	Cancel key.Binding
+	Quit   key.Binding
+}
+
+func (k SearchKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Mode, k.Field, k.Run, k.Cancel, k.Quit}
+}
+
+func (k SearchKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Mode, k.Field, k.Run},
+		{k.Cancel, k.Quit},
+	}
+}
+
+func NewSearchKeyMap() SearchKeyMap {
+	return SearchKeyMap{
+		Mode:   key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "local/server")),
+		Field:  key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", "field")),
+		Run:    key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "search")),
+		Cancel: key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "close")),
+		Quit:   key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit")),
+	}
+}
+
+// Keys used to answer a yes/no question, where any other key means no
+type ConfirmKeyMap struct {
+	Yes  key.Binding
+	No   key.Binding
+	Quit key.Binding
+}
+
+func (k ConfirmKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Yes, k.No, k.Quit}
+}
+
+func (k ConfirmKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Yes, k.No, k.Quit}}
+}
+
+func NewConfirmKeyMap() ConfirmKeyMap {
+	return ConfirmKeyMap{
+		Yes:  key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "yes")),
+		No:   key.NewBinding(key.WithKeys("n", "esc"), key.WithHelp("n", "no")),
+		Quit: key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit")),
 	}
 }
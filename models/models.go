@@ -2,163 +2,1651 @@
 package models
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/Zachkp/GoMail/email"
 	"github.com/Zachkp/GoMail/styles"
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/emersion/go-imap"
 )
 
 type model struct {
+	session       *email.Session
 	table         table.Model
 	width, height int
 	emails        []email.Email
 	viewingEmail  bool
 	selectedEmail email.Email
+	bodyLoading   bool
 	emailViewport viewport.Model
+	attachments   AttachmentState
+
+	// Show every recipient of the open message instead of the first few
+	allRecipients bool
 
 	// Add search functionality
 	search SearchState
+
+	// Folder sidebar
+	folders FolderState
+
+	// Background loading state
+	loading        bool
+	loadingMailbox string
+	loadErr        error
+	spinner        spinner.Model
+
+	// Paging back through older messages
+	loadingMore   bool
+	reachedOldest bool
+	status        string
+
+	// New mail pushed by the IDLE watcher, keyed by UID until opened
+	watcher  *email.Watcher
+	arrivals map[uint32]bool
+
+	// Compose view
+	composing bool
+	compose   ComposeState
+
+	// File picker for attachments, reopened where it was last used
+	filePicker    FilePickerState
+	lastAttachDir string
+
+	// Folder picker for moving messages
+	picker PickerState
+
+	// Messages waiting for the user to confirm deleting them for good
+	confirmDelete []uint32
+
+	// Messages marked for bulk actions
+	selection SelectionState
+
+	// Show only flagged messages
+	flaggedOnly bool
+
+	// Conversation threading, and the messages of the open conversation
+	threads ThreadState
+	thread  []email.Email
+
+	// Drafts autosave; draftGen invalidates ticks from earlier forms
+	draftGen      int
+	draftsMailbox string
+	openDrafts    bool
+	lastSentID    string
+}
+
+func (m model) Init() tea.Cmd {
+	return tea.Batch(
+		m.spinner.Tick,
+		loadMailboxesCmd(m.session),
+		loadEmailsCmd(m.session, m.loadingMailbox),
+	)
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.table.SetColumns(CreateColumns(m.width - 20 - sidebarWidth))
+		m.table.SetHeight(m.tableHeight())
+
+		if m.viewingEmail {
+			m.emailViewport.Width = m.width - 8
+			m.emailViewport.Height = m.emailViewportHeight()
+		}
+
+		// Update search input width
+		m.search.searchInput.Width = m.width - 20 - sidebarWidth
+
+		m.compose.SetSize(m.width-12, m.height-8)
+
+		return m, nil
+
+	case spinner.TickMsg:
+		if !m.loading && !m.loadingMore && !m.compose.sending && !m.search.pending {
+			return m, nil
+		}
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
+	case mailboxesLoadedMsg:
+		if msg.err != nil {
+			m.loadErr = fmt.Errorf("failed to load folders: %w", msg.err)
+			return m, nil
+		}
+		m.folders.mailboxes = msg.mailboxes
+		return m, draftsMailboxCmd(m.session)
+
+	case draftsMailboxMsg:
+		open := m.openDrafts
+		m.openDrafts = false
+		if msg.err != nil {
+			if open {
+				m.status = msg.err.Error()
+			}
+			return m, nil
+		}
+		m.draftsMailbox = msg.name
+		if open {
+			return m, m.loadMailbox(msg.name)
+		}
+		return m, nil
+
+	case draftLoadedMsg:
+		if msg.err != nil {
+			if cmd := m.resyncIfReset(msg.err); cmd != nil {
+				return m, cmd
+			}
+			m.status = fmt.Sprintf("Failed to open draft: %v", msg.err)
+			return m, nil
+		}
+		c := InitComposeFrom(msg.draft)
+		c.hasDraft = true
+		return m, m.startCompose(c)
+
+	case forwardReadyMsg:
+		if msg.err != nil {
+			if cmd := m.resyncIfReset(msg.err); cmd != nil {
+				return m, cmd
+			}
+			m.status = fmt.Sprintf("Failed to forward: %v", msg.err)
+			return m, nil
+		}
+		m.status = ""
+		return m, m.startCompose(InitComposeFrom(msg.forward))
+
+	case draftTickMsg:
+		if !m.composing || msg.gen != m.draftGen {
+			return m, nil
+		}
+		return m, tea.Batch(m.autosaveDraft(), draftTickCmd(msg.gen))
+
+	case draftSavedMsg:
+		return m, m.draftSaved(msg)
+
+	case flagsUpdatedMsg:
+		if msg.err != nil {
+			if msg.mailbox == m.folders.current {
+				if cmd := m.resyncIfReset(msg.err); cmd != nil {
+					return m, cmd
+				}
+				// Put the local copies back the way the server still has them
+				m.applyFlag(msg.uids, msg.flag, !msg.on)
+			}
+			m.status = fmt.Sprintf("Failed to update flags: %v", msg.err)
+		}
+		return m, nil
+
+	case flagsSyncedMsg:
+		if msg.mailbox != m.folders.current {
+			return m, nil
+		}
+		if msg.err != nil {
+			if cmd := m.resyncIfReset(msg.err); cmd != nil {
+				return m, cmd
+			}
+			m.status = fmt.Sprintf("Failed to refresh flags: %v", msg.err)
+			return m, nil
+		}
+		m.syncFlags(msg.flags)
+
+		// Messages the server no longer has were removed by another client
+		var gone []uint32
+		for _, uid := range msg.uids {
+			if _, ok := msg.flags[uid]; !ok {
+				gone = append(gone, uid)
+			}
+		}
+		if len(gone) > 0 {
+			m.removeLocal(gone)
+		}
+		return m, nil
+
+	case deleteCheckedMsg:
+		if msg.mailbox != m.folders.current {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Could not delete messages: %v", msg.err)
+			return m, nil
+		}
+		if !msg.forGood {
+			m.removeLocal(msg.uids)
+			return m, deleteCmd(m.session, msg.mailbox, msg.uids)
+		}
+		m.confirmDelete = msg.uids
+		m.status = fmt.Sprintf("Delete %d message(s) for good? This cannot be undone.", len(msg.uids))
+		return m, nil
+
+	case messagesMovedMsg:
+		if msg.err != nil {
+			// Reload so messages that were hidden early come back
+			m.status = fmt.Sprintf("Could not %s messages: %v", msg.verb, msg.err)
+			if msg.mailbox == m.folders.current {
+				return m, m.loadMailbox(m.folders.current)
+			}
+			return m, nil
+		}
+		m.status = fmt.Sprintf("%s %d message(s)", msg.done, len(msg.uids))
+		return m, nil
+
+	case attachmentsSavedMsg:
+		if msg.err != nil {
+			if msg.mailbox == m.folders.current {
+				if cmd := m.resyncIfReset(msg.err); cmd != nil {
+					return m, cmd
+				}
+			}
+			m.status = fmt.Sprintf("Failed to save attachments: %v", msg.err)
+			return m, nil
+		}
+		if len(msg.paths) == 1 {
+			m.status = "Saved " + msg.paths[0]
+		} else {
+			m.status = fmt.Sprintf("Saved %d attachments to %s", len(msg.paths), msg.dir)
+		}
+		return m, nil
+
+	case statusMsg:
+		m.status = string(msg)
+		return m, nil
+
+	case emailsLoadedMsg:
+		// Ignore results for a mailbox the user has since navigated away from
+		if msg.mailbox != m.loadingMailbox {
+			return m, nil
+		}
+		m.loading = false
+		if msg.err != nil {
+			m.loadErr = fmt.Errorf("failed to load %s: %w", msg.mailbox, msg.err)
+			return m, nil
+		}
+		m.loadErr = nil
+		return m, m.showMailbox(msg.mailbox, msg.emails)
+
+	case newMailMsg:
+		// Events from a watcher that has since been replaced are dropped
+		if msg.watcher != m.watcher {
+			return m, nil
+		}
+		return m, tea.Batch(m.refresh(), waitForMailCmd(m.watcher))
+
+	case newEmailsLoadedMsg:
+		if msg.mailbox != m.folders.current {
+			return m, nil
+		}
+		if msg.err != nil {
+			if cmd := m.resyncIfReset(msg.err); cmd != nil {
+				return m, cmd
+			}
+			m.status = fmt.Sprintf("%s: %v", newMailFailed, msg.err)
+			return m, nil
+		}
+		// A successful fetch also brings in whatever a failed one missed
+		if strings.HasPrefix(m.status, newMailFailed) {
+			m.status = ""
+		}
+		m.addArrivals(msg.emails)
+		return m, m.reloadThreads()
+
+	case olderEmailsLoadedMsg:
+		if msg.mailbox != m.folders.current {
+			return m, nil
+		}
+		m.loadingMore = false
+		if msg.err != nil {
+			if cmd := m.resyncIfReset(msg.err); cmd != nil {
+				return m, cmd
+			}
+			m.status = fmt.Sprintf("Failed to load older messages: %v", msg.err)
+			return m, nil
+		}
+		m.status = ""
+		m.reachedOldest = len(msg.emails) < pageSize
+		m.emails = append(m.emails, msg.emails...)
+		m.updateTableRows()
+		return m, m.reloadThreads()
+
+	case searchResultsMsg:
+		if msg.mailbox != m.folders.current {
+			return m, nil
+		}
+		if msg.err != nil {
+			if cmd := m.resyncIfReset(msg.err); cmd != nil {
+				return m, cmd
+			}
+		}
+		if m.search.SetServerResults(msg.query, msg.field, msg.emails, msg.total, msg.err) {
+			m.selection.Clear()
+			m.updateTableRows()
+			m.table.SetCursor(0)
+		}
+		return m, nil
+
+	case threadsLoadedMsg:
+		if msg.mailbox != m.folders.current || !m.threads.enabled {
+			return m, nil
+		}
+		m.threads.loading = false
+		roots := msg.roots
+		if msg.err != nil {
+			if cmd := m.resyncIfReset(msg.err); cmd != nil {
+				return m, cmd
+			}
+			// Fall back to threading the headers already loaded
+			m.status = fmt.Sprintf("Server threading failed, threaded locally: %v", msg.err)
+			roots = email.ThreadEmails(m.emails)
+		}
+		uid := m.cursorUID()
+		m.threads.roots = roots
+		m.updateTableRows()
+		m.setCursorUID(uid)
+		return m, nil
+
+	case bodyLoadedMsg:
+		if msg.mailbox != m.folders.current {
+			return m, nil
+		}
+		if msg.err != nil {
+			if cmd := m.resyncIfReset(msg.err); cmd != nil {
+				return m, cmd
+			}
+			if m.viewingEmail && m.selectedEmail.UID == msg.uid {
+				m.bodyLoading = false
+				m.emailViewport.SetContent(fmt.Sprintf("Failed to load message: %v", msg.err))
+			}
+			return m, nil
+		}
+		if m.selectedEmail.UID == msg.uid {
+			m.bodyLoading = false
+		}
+
+		// Keep the body so it is searchable and opens instantly next time
+		for i := range m.emails {
+			if m.emails[i].UID == msg.uid {
+				m.emails[i].Body = msg.body
+			}
+		}
+		if m.viewingEmail && m.thread != nil {
+			for i := range m.thread {
+				if m.thread[i].UID == msg.uid {
+					m.thread[i].Body = msg.body
+					m.emailViewport.SetContent(conversationView(m.thread, m.width-14, m.search.Highlights("body")))
+				}
+			}
+			if m.selectedEmail.UID == msg.uid {
+				m.selectedEmail.Body = msg.body
+			}
+			return m, nil
+		}
+		if m.viewingEmail && m.selectedEmail.UID == msg.uid {
+			m.selectedEmail.Body = msg.body
+			m.emailViewport.SetContent(highlightText(msg.body, m.search.Highlights("body")))
+		}
+		return m, nil
+
+	case mailSentMsg:
+		m.compose.sending = false
+
+		// The message went out even if saving a copy failed
+		var saveErr *email.SaveError
+		if errors.As(msg.err, &saveErr) {
+			m.status = "Message sent, but " + saveErr.Err.Error()
+			return m, m.finishSend()
+		}
+
+		if msg.err != nil {
+			m.compose.err = fmt.Errorf("send failed: %w", msg.err)
+			return m, nil
+		}
+		m.status = "Message sent"
+		return m, m.finishSend()
+
+	case editorFinishedMsg:
+		if msg.err != nil {
+			m.compose.err = msg.err
+			return m, nil
+		}
+		c := InitComposeFrom(msg.msg)
+		c.hasDraft = m.compose.hasDraft
+		c.lastSaved = m.compose.lastSaved
+		return m, m.setCompose(c)
+
+	case tea.KeyMsg:
+		// The compose form gets every key while it is open
+		if m.composing {
+			return m, m.updateCompose(msg)
+		}
+
+		// The folder picker gets every key while it is open
+		if m.picker.active {
+			return m, m.updatePicker(msg)
+		}
+
+		// Deleting for good needs an answer before anything else
+		if m.confirmDelete != nil {
+			return m, m.updateConfirmDelete(msg)
+		}
+
+		// Handle search input first if we're typing a search. Every letter
+		// goes to the input, so queries like from:alice can be typed.
+		if m.search.isSearching && m.search.searchInput.Focused() && !m.viewingEmail {
+			switch {
+			case key.Matches(msg, SearchKeys.Quit):
+				return m, tea.Quit
+			case key.Matches(msg, SearchKeys.Cancel):
+				m.search.ToggleSearch(m.emails)
+				m.updateTableRows()
+				return m, nil
+			case key.Matches(msg, SearchKeys.Mode):
+				m.search.ToggleServer()
+				m.selection.Clear()
+				m.updateTableRows()
+				m.table.SetCursor(0)
+				return m, nil
+			case key.Matches(msg, SearchKeys.Field):
+				m.search.CycleField()
+				m.selection.Clear()
+				m.updateTableRows()
+				m.table.SetCursor(0)
+				return m, nil
+			case key.Matches(msg, SearchKeys.Run):
+				// Leave the input but keep the results; the search key
+				// goes back to it and esc closes the search
+				m.search.searchInput.Blur()
+				if !m.search.server {
+					return m, nil
+				}
+				query := m.search.StartServerSearch()
+				m.updateTableRows()
+				if query == "" {
+					return m, nil
+				}
+				return m, tea.Batch(m.spinner.Tick, serverSearchCmd(m.session, m.folders.current, query, m.search.field))
+			default:
+				// Update search input
+				m.search.searchInput, cmd = m.search.searchInput.Update(msg)
+				if m.search.server {
+					m.search.CheckQuery()
+				} else {
+					m.search.UpdateSearch(m.search.searchInput.Value(), m.emails)
+					m.updateTableRows()
+				}
+				return m, cmd
+			}
+		}
+
+		// Handle folder sidebar navigation
+		if m.folders.focused && !m.viewingEmail {
+			switch {
+			case key.Matches(msg, CommonKeys.Quit):
+				return m, tea.Quit
+			case key.Matches(msg, CommonKeys.Folders), msg.Type == tea.KeyEscape:
+				m.folders.focused = false
+				m.table.Focus()
+			case key.Matches(msg, CommonKeys.Up):
+				m.folders.CursorUp()
+			case key.Matches(msg, CommonKeys.Down):
+				m.folders.CursorDown()
+			case key.Matches(msg, CommonKeys.Select):
+				m.folders.focused = false
+				m.table.Focus()
+				if name := m.folders.Highlighted(); name != "" {
+					return m, m.loadMailbox(name)
+				}
+			}
+			return m, nil
+		}
+
+		// The attachment list gets every key while it is open
+		if m.viewingEmail && m.attachments.active {
+			return m, m.updateAttachments(msg)
+		}
+
+		// Actions on the open message
+		if m.viewingEmail {
+			switch {
+			case key.Matches(msg, CommonKeys.Attachments):
+				if m.thread == nil && len(m.selectedEmail.Attachments) > 0 {
+					m.attachments = OpenAttachments()
+					m.emailViewport.Height = m.emailViewportHeight()
+				}
+				return m, nil
+			case key.Matches(msg, CommonKeys.Recipients):
+				if m.thread == nil && hasLongRecipients(m.selectedEmail) {
+					m.allRecipients = !m.allRecipients
+					m.emailViewport.Height = m.emailViewportHeight()
+				}
+				return m, nil
+			case key.Matches(msg, CommonKeys.Reply, CommonKeys.ReplyAll, CommonKeys.Forward) && m.bodyLoading:
+				// Replies quote the body, so wait until it is here
+				m.status = "Still loading the message..."
+				return m, nil
+			case key.Matches(msg, CommonKeys.Reply):
+				reply := email.Reply(m.selectedEmail, false, m.session.Username())
+				return m, m.startCompose(InitComposeFrom(reply))
+			case key.Matches(msg, CommonKeys.ReplyAll):
+				reply := email.Reply(m.selectedEmail, true, m.session.Username())
+				return m, m.startCompose(InitComposeFrom(reply))
+			case key.Matches(msg, CommonKeys.Forward):
+				if len(m.selectedEmail.Attachments) == 0 {
+					return m, m.startCompose(InitComposeFrom(email.Forward(m.selectedEmail)))
+				}
+				m.status = "Fetching attachments to forward..."
+				return m, forwardCmd(m.session, m.folders.current, m.selectedEmail)
+			}
+		}
+
+		// Regular key handling
+		switch {
+		case key.Matches(msg, CommonKeys.Quit):
+			return m, tea.Quit
+
+		case key.Matches(msg, CommonKeys.Help):
+			CommonHelp.ShowAll = !CommonHelp.ShowAll
+			m.table.SetHeight(m.tableHeight())
+			m.emailViewport.Height = m.emailViewportHeight()
+			return m, nil
+
+		case key.Matches(msg, CommonKeys.Search):
+			if !m.viewingEmail {
+				// Go back to a search whose results are still shown
+				if m.search.isSearching {
+					m.search.searchInput.Focus()
+					return m, textinput.Blink
+				}
+				m.search.ToggleSearch(m.emails)
+				m.updateTableRows()
+				return m, nil
+			}
+
+		case key.Matches(msg, CommonKeys.Retry):
+			if m.loadErr != nil && !m.loading {
+				return m, m.retryLoad()
+			}
+
+		case key.Matches(msg, CommonKeys.Compose):
+			return m, m.startCompose(InitCompose())
+
+		case key.Matches(msg, CommonKeys.ToggleRead):
+			if targets := m.targetEmails(); len(targets) > 0 {
+				return m, m.toggleFlag(targets, imap.SeenFlag)
+			}
+
+		case key.Matches(msg, CommonKeys.Flag):
+			if targets := m.targetEmails(); len(targets) > 0 {
+				return m, m.toggleFlag(targets, imap.FlaggedFlag)
+			}
+
+		case key.Matches(msg, CommonKeys.Flagged):
+			if !m.viewingEmail {
+				m.flaggedOnly = !m.flaggedOnly
+				m.selection.Clear()
+				m.updateTableRows()
+				m.table.SetCursor(0)
+				return m, nil
+			}
+
+		case key.Matches(msg, CommonKeys.Threads):
+			if !m.viewingEmail {
+				uid := m.cursorUID()
+				m.threads.Toggle()
+				m.selection.Clear()
+				m.updateTableRows()
+				m.setCursorUID(uid)
+				return m, m.reloadThreads()
+			}
+
+		case key.Matches(msg, CommonKeys.Fold):
+			if !m.viewingEmail && m.threads.enabled {
+				if row, ok := m.cursorThreadRow(); ok && len(row.members) > 1 {
+					m.threads.Fold(row.key)
+					m.selection.Clear()
+					m.updateTableRows()
+					m.setCursorUID(row.members[0].UID)
+				}
+				return m, nil
+			}
+
+		case key.Matches(msg, CommonKeys.Refresh):
+			if !m.loading {
+				return m, m.refresh()
+			}
+
+		case key.Matches(msg, CommonKeys.Delete):
+			if uids := uidsOf(m.targetEmails()); len(uids) > 0 {
+				return m, checkDeleteCmd(m.session, m.folders.current, uids)
+			}
+
+		case key.Matches(msg, CommonKeys.Archive):
+			if uids := uidsOf(m.targetEmails()); len(uids) > 0 {
+				m.removeLocal(uids)
+				return m, archiveCmd(m.session, m.folders.current, uids)
+			}
+
+		case key.Matches(msg, CommonKeys.Move):
+			if uids := uidsOf(m.targetEmails()); len(uids) > 0 {
+				// The picker is shown above the table
+				m.viewingEmail = false
+				m.openPicker(uids)
+				return m, textinput.Blink
+			}
+
+		case key.Matches(msg, CommonKeys.Mark):
+			if !m.viewingEmail {
+				currentEmails := m.getCurrentEmails()
+				row := m.table.Cursor()
+				if row >= 0 && row < len(currentEmails) {
+					m.selection.Toggle(currentEmails[row].UID)
+					m.table.MoveDown(1)
+					m.updateTableRows()
+				}
+				return m, nil
+			}
+
+		case key.Matches(msg, CommonKeys.Visual):
+			if !m.viewingEmail {
+				if m.selection.visual {
+					m.selection.EndVisual(m.getCurrentEmails(), m.table.Cursor())
+				} else {
+					m.selection.StartVisual(m.table.Cursor())
+				}
+				m.updateTableRows()
+				return m, nil
+			}
+
+		case key.Matches(msg, CommonKeys.Unmark):
+			if !m.viewingEmail && m.selection.Active() {
+				m.selection.Clear()
+				m.updateTableRows()
+				return m, nil
+			}
+			// Otherwise esc closes the search results
+			if !m.viewingEmail && m.search.isSearching {
+				m.search.ToggleSearch(m.emails)
+				m.updateTableRows()
+				m.table.SetCursor(0)
+				return m, nil
+			}
+
+		case key.Matches(msg, CommonKeys.Drafts):
+			if m.viewingEmail {
+				break
+			}
+			if m.draftsMailbox != "" {
+				return m, m.loadMailbox(m.draftsMailbox)
+			}
+			m.openDrafts = true
+			return m, draftsMailboxCmd(m.session)
+
+		case key.Matches(msg, CommonKeys.LoadMore):
+			if !m.viewingEmail {
+				return m, m.loadMore()
+			}
+
+		case key.Matches(msg, CommonKeys.Folders):
+			if !m.viewingEmail {
+				m.folders.focused = true
+				m.table.Blur()
+				return m, nil
+			}
+
+		case key.Matches(msg, CommonKeys.Select):
+			if !m.viewingEmail {
+				selectedRow := m.table.Cursor()
+				currentEmails := m.getCurrentEmails()
+				// Drafts open in the compose view to be resumed
+				if m.folders.current == m.draftsMailbox && selectedRow >= 0 && selectedRow < len(currentEmails) {
+					return m, loadDraftCmd(m.session, m.folders.current, currentEmails[selectedRow].UID)
+				}
+
+				// Threads open as a whole conversation
+				if row, ok := m.cursorThreadRow(); ok && len(row.members) > 1 {
+					return m, m.openThread(row.members)
+				}
+
+				if selectedRow >= 0 && selectedRow < len(currentEmails) {
+					m.selectedEmail = currentEmails[selectedRow]
+					m.viewingEmail = true
+					m.thread = nil
+					delete(m.arrivals, m.selectedEmail.UID)
+
+					// Opening a message marks it read
+					var seenCmd tea.Cmd
+					if !m.selectedEmail.Seen() {
+						seenCmd = m.setFlag([]uint32{m.selectedEmail.UID}, imap.SeenFlag, true)
+					}
+
+					m.attachments = AttachmentState{}
+					m.allRecipients = false
+					m.emailViewport = viewport.New(m.width-8, m.emailViewportHeight())
+					m.bodyLoading = m.selectedEmail.Body == ""
+					if !m.bodyLoading {
+						m.emailViewport.SetContent(highlightText(m.selectedEmail.Body, m.search.Highlights("body")))
+						return m, seenCmd
+					}
+					m.emailViewport.SetContent("Loading message...")
+					return m, tea.Batch(seenCmd, loadBodyCmd(m.session, m.folders.current, m.selectedEmail.UID))
+				}
+			}
+
+		case key.Matches(msg, CommonKeys.Back):
+			if m.viewingEmail {
+				m.viewingEmail = false
+				m.thread = nil
+			}
+
+		case key.Matches(msg, CommonKeys.Up):
+			if m.viewingEmail {
+				m.emailViewport.LineUp(1)
+				return m, nil
+			} else {
+				m.table, cmd = m.table.Update(msg)
+				if m.selection.visual {
+					m.updateTableRows()
+				}
+				return m, cmd
+			}
+
+		case key.Matches(msg, CommonKeys.Down):
+			if m.viewingEmail {
+				m.emailViewport.LineDown(1)
+				return m, nil
+			} else {
+				m.table, cmd = m.table.Update(msg)
+				if m.selection.visual {
+					m.updateTableRows()
+				}
+				return m, tea.Batch(cmd, m.maybeLoadMore())
+			}
+		}
+	}
+
+	if m.composing {
+		return m, m.compose.Update(msg)
+	}
+
+	if !m.viewingEmail && !m.search.searchInput.Focused() {
+		m.table, cmd = m.table.Update(msg)
+		if m.selection.visual {
+			m.updateTableRows()
+		}
+		return m, tea.Batch(cmd, m.maybeLoadMore())
+	}
+
+	return m, cmd
+}
+
+// Helper function to get current emails (filtered or all), in the order of
+// the table rows
+func (m model) getCurrentEmails() []email.Email {
+	if !m.threads.enabled {
+		return m.filteredEmails()
+	}
+
+	rows := m.threads.Rows(m.filteredEmails())
+	emails := make([]email.Email, len(rows))
+	for i, row := range rows {
+		emails[i] = row.email
+	}
+	return emails
+}
+
+// Helper function to apply the search and flagged filters
+func (m model) filteredEmails() []email.Email {
+	emails := m.emails
+	if m.search.isSearching {
+		emails = m.search.filteredEmails
+	}
+	if !m.flaggedOnly {
+		return emails
+	}
+
+	var flagged []email.Email
+	for _, e := range emails {
+		if e.Flagged() {
+			flagged = append(flagged, e)
+		}
+	}
+	return flagged
+}
+
+// Helper function to start loading another mailbox in the background
+func (m *model) loadMailbox(name string) tea.Cmd {
+	m.loading = true
+	m.loadingMailbox = name
+	m.loadErr = nil
+	return tea.Batch(m.spinner.Tick, loadEmailsCmd(m.session, name))
+}
+
+// Helper function to reload the current mailbox if an operation failed
+// because its UIDs were reset on the server
+func (m *model) resyncIfReset(err error) tea.Cmd {
+	if !errors.Is(err, email.ErrUIDValidityChanged) {
+		return nil
+	}
+	m.status = "Mailbox changed on the server, reloading..."
+	return m.loadMailbox(m.folders.current)
+}
+
+// Helper function to retry whatever failed to load
+func (m *model) retryLoad() tea.Cmd {
+	mailbox := m.loadingMailbox
+	if mailbox == "" {
+		mailbox = m.folders.current
+	}
+
+	cmds := []tea.Cmd{m.loadMailbox(mailbox)}
+	if len(m.folders.mailboxes) == 0 {
+		cmds = append(cmds, loadMailboxesCmd(m.session))
+	}
+	return tea.Batch(cmds...)
+}
+
+// Helper function to open the compose view, going straight to the
+// external editor if the user prefers it
+func (m *model) startCompose(c ComposeState) tea.Cmd {
+	// Only autosave once the user has changed something
+	c.lastSaved = c.Message().Template()
+
+	cmd := m.setCompose(c)
+	if m.session.Config().ComposeInEditor {
+		return editInEditorCmd(m.compose.Message())
+	}
+	return cmd
+}
+
+// Helper function to show a prepared compose form
+func (m *model) setCompose(c ComposeState) tea.Cmd {
+	c.SetSize(m.width-12, m.height-8)
+	m.compose = c
+	m.composing = true
+	m.draftGen++
+	return tea.Batch(textarea.Blink, draftTickCmd(m.draftGen))
+}
+
+// Helper function to save the compose form as a draft if it changed
+func (m *model) autosaveDraft() tea.Cmd {
+	if m.compose.savingDraft || m.compose.sending || !m.compose.Dirty() {
+		return nil
+	}
+
+	msg := m.compose.Message()
+	m.compose.savingDraft = true
+	m.compose.pendingSave = msg.Template()
+	return saveDraftCmd(m.session, msg)
+}
+
+// Helper function to record the result of a draft save
+func (m *model) draftSaved(msg draftSavedMsg) tea.Cmd {
+	// The form was closed or replaced while the save was running
+	if !m.composing || msg.messageID != m.compose.messageID {
+		switch {
+		case msg.messageID == m.lastSentID:
+			// A save raced with sending, remove the stale draft again
+			return deleteDraftCmd(m.session, msg.messageID)
+		case msg.err != nil:
+			m.status = fmt.Sprintf("Draft not saved: %v", msg.err)
+		default:
+			m.status = "Draft saved"
+		}
+		return nil
+	}
+
+	m.compose.savingDraft = false
+	if msg.err != nil {
+		m.compose.draftStatus = fmt.Sprintf("Draft not saved: %v", msg.err)
+		return nil
+	}
+	m.compose.hasDraft = true
+	m.compose.lastSaved = m.compose.pendingSave
+	m.compose.draftStatus = "Draft saved at " + time.Now().Format("15:04")
+	return nil
+}
+
+// Helper function to close the compose form after a successful send
+func (m *model) finishSend() tea.Cmd {
+	m.composing = false
+	m.lastSentID = m.compose.messageID
+	if m.compose.hasDraft {
+		return deleteDraftCmd(m.session, m.compose.messageID)
+	}
+	return nil
+}
+
+// Helper function to handle keys while composing
+func (m *model) updateCompose(msg tea.KeyMsg) tea.Cmd {
+	if m.filePicker.active {
+		return m.updateFilePicker(msg)
+	}
+
+	switch {
+	case key.Matches(msg, ComposeKeys.Quit):
+		return tea.Quit
+	case key.Matches(msg, ComposeKeys.Cancel):
+		if m.compose.sending {
+			return nil
+		}
+		m.composing = false
+
+		// Keep unsaved work as a draft
+		if m.compose.Dirty() {
+			m.status = "Saving draft..."
+			return saveDraftCmd(m.session, m.compose.Message())
+		}
+		return nil
+	case key.Matches(msg, ComposeKeys.Send):
+		if m.compose.sending {
+			return nil
+		}
+		out := m.compose.Message()
+		if !m.compose.attachWarned && out.MentionsAttachment() {
+			m.compose.attachWarned = true
+			m.compose.err = fmt.Errorf("the message mentions an attachment but nothing is attached, press %s again to send anyway",
+				ComposeKeys.Send.Help().Key)
+			return nil
+		}
+		m.compose.sending = true
+		m.compose.err = nil
+		return tea.Batch(m.spinner.Tick, sendMailCmd(m.session, out))
+	case key.Matches(msg, ComposeKeys.Attach):
+		if m.compose.sending {
+			return nil
+		}
+		dir := m.lastAttachDir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		m.filePicker = OpenFilePicker(dir)
+		return textinput.Blink
+	case key.Matches(msg, ComposeKeys.Detach):
+		if !m.compose.sending {
+			m.compose.DetachLast()
+		}
+		return nil
+	case key.Matches(msg, ComposeKeys.Editor):
+		if m.compose.sending {
+			return nil
+		}
+		return editInEditorCmd(m.compose.Message())
+	case key.Matches(msg, ComposeKeys.NextField):
+		m.compose.NextField()
+		return nil
+	case key.Matches(msg, ComposeKeys.PrevField):
+		m.compose.PrevField()
+		return nil
+	}
+	return m.compose.Update(msg)
+}
+
+// Helper function to size the message table below the rest of the view
+func (m model) tableHeight() int {
+	return m.height - 20 - m.extraHelpHeight()
+}
+
+// Helper function to find how many lines the full help takes beyond the
+// single line of the short help
+func (m model) extraHelpHeight() int {
+	return lipgloss.Height(m.helpView()) - 1
+}
+
+// Helper function to size the message body below the header and attachments
+func (m model) emailViewportHeight() int {
+	containerHeight := m.height - 6 - m.extraHelpHeight()
+	headerHeight := lipgloss.Height(m.messageHeaderView())
+	if m.thread == nil {
+		headerHeight += m.attachments.Height(m.selectedEmail.Attachments)
+	}
+	viewportHeight := containerHeight - headerHeight
+	if viewportHeight < 5 {
+		viewportHeight = 5
+	}
+	return viewportHeight
+}
+
+// Helper function to render the header of the open message or conversation
+func (m model) messageHeaderView() string {
+	if m.thread != nil {
+		return threadHeaderView(m.thread, m.width-14)
+	}
+	return emailHeaderView(m.selectedEmail, m.width-14, m.allRecipients, m.search.Highlights)
+}
+
+// Helper function to handle keys while the attachment list is open
+func (m *model) updateAttachments(msg tea.KeyMsg) tea.Cmd {
+	atts := m.selectedEmail.Attachments
+
+	if m.attachments.prompting {
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return tea.Quit
+		case tea.KeyEscape:
+			m.attachments.prompting = false
+		case tea.KeyEnter:
+			dir := strings.TrimSpace(m.attachments.dirInput.Value())
+			if dir == "" {
+				return nil
+			}
+			selected := atts
+			if !m.attachments.saveAll {
+				selected = atts[m.attachments.cursor : m.attachments.cursor+1]
+			}
+			m.attachments.prompting = false
+			m.emailViewport.Height = m.emailViewportHeight()
+			m.status = "Saving attachments..."
+			return saveAttachmentsCmd(m.session, m.folders.current, m.selectedEmail.UID, selected, dir)
+		default:
+			var cmd tea.Cmd
+			m.attachments.dirInput, cmd = m.attachments.dirInput.Update(msg)
+			return cmd
+		}
+		m.emailViewport.Height = m.emailViewportHeight()
+		return nil
+	}
+
+	switch {
+	case key.Matches(msg, AttachmentKeys.Quit):
+		return tea.Quit
+	case key.Matches(msg, AttachmentKeys.Close):
+		m.attachments.active = false
+	case key.Matches(msg, AttachmentKeys.Up):
+		m.attachments.CursorUp()
+	case key.Matches(msg, AttachmentKeys.Down):
+		m.attachments.CursorDown(len(atts))
+	case key.Matches(msg, AttachmentKeys.Open):
+		att := atts[m.attachments.cursor]
+		m.status = "Opening " + att.Filename + "..."
+		return openAttachmentCmd(m.session, m.folders.current, m.selectedEmail.UID, att)
+	case key.Matches(msg, AttachmentKeys.Save):
+		m.attachments.StartSave(false, m.session.Config().AttachmentDir)
+	case key.Matches(msg, AttachmentKeys.SaveAll):
+		m.attachments.StartSave(true, m.session.Config().AttachmentDir)
+	}
+	m.emailViewport.Height = m.emailViewportHeight()
+	return nil
 }
 
-func (m model) Init() tea.Cmd { return nil }
+// Helper function to handle keys while the attachment file picker is open
+func (m *model) updateFilePicker(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return tea.Quit
+	case tea.KeyEscape:
+		m.filePicker.active = false
+		return nil
+	case tea.KeyUp, tea.KeyCtrlP:
+		m.filePicker.CursorUp()
+		return nil
+	case tea.KeyDown, tea.KeyCtrlN:
+		m.filePicker.CursorDown()
+		return nil
+	case tea.KeyEnter, tea.KeyTab:
+		path, err := m.filePicker.Choose()
+		if err != nil {
+			m.filePicker.err = err
+			return nil
+		}
+		if path == "" {
+			// A directory was entered, keep browsing
+			return nil
+		}
+		m.filePicker.active = false
+		m.lastAttachDir = filepath.Dir(path)
+		m.compose.Attach(path)
+		return nil
+	}
 
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
+	m.filePicker.input, cmd = m.filePicker.input.Update(msg)
+	m.filePicker.filter()
+	return cmd
+}
 
-	switch msg := msg.(type) {
-	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height
-		m.table.SetColumns(CreateColumns(m.width - 20))
-		m.table.SetHeight(m.height - 20)
+// Helper function to find the message an action applies to: the open
+// message, or the one under the table cursor
+func (m model) targetEmail() (email.Email, bool) {
+	if m.viewingEmail {
+		return m.selectedEmail, true
+	}
+
+	currentEmails := m.getCurrentEmails()
+	row := m.table.Cursor()
+	if row >= 0 && row < len(currentEmails) {
+		return currentEmails[row], true
+	}
+	return email.Email{}, false
+}
+
+// Helper function to find the messages an action applies to: the marked
+// messages if there are any, otherwise the single target message. The marks
+// are used up by the action.
+func (m *model) targetEmails() []email.Email {
+	if !m.viewingEmail && m.selection.Active() {
+		selected := m.selection.Selected(m.getCurrentEmails(), m.table.Cursor())
+		m.selection.Clear()
+		return m.expandThreads(selected)
+	}
 
+	if target, ok := m.targetEmail(); ok {
 		if m.viewingEmail {
-			containerHeight := m.height - 6
-			headerHeight := 4
-			viewportHeight := containerHeight - headerHeight - 2
-			if viewportHeight < 5 {
-				viewportHeight = 5
-			}
-			m.emailViewport.Width = m.width - 8
-			m.emailViewport.Height = viewportHeight
+			return []email.Email{target}
 		}
+		return m.expandThreads([]email.Email{target})
+	}
+	return nil
+}
 
-		// Update search input width
-		m.search.searchInput.Width = m.width - 20
+// Helper function to replace collapsed threads in a set of messages with
+// all of their messages, so actions apply to the whole conversation
+func (m model) expandThreads(emails []email.Email) []email.Email {
+	if !m.threads.enabled {
+		return emails
+	}
 
-		return m, nil
+	collapsed := make(map[uint32][]email.Email)
+	for _, row := range m.threads.Rows(m.filteredEmails()) {
+		if row.collapsed {
+			collapsed[row.email.UID] = row.members
+		}
+	}
 
-	case tea.KeyMsg:
-		// Handle search input first if we're searching
-		if m.search.isSearching && !m.viewingEmail {
-			switch {
-			case key.Matches(msg, CommonKeys.Search): // Toggle search off
-				m.search.ToggleSearch(m.emails)
-				m.updateTableRows()
-				return m, nil
-			case key.Matches(msg, CommonKeys.Quit):
-				return m, tea.Quit
-			case msg.Type == tea.KeyEscape:
-				m.search.ToggleSearch(m.emails)
-				m.updateTableRows()
-				return m, nil
-			case msg.Type == tea.KeyEnter:
-				// Exit search mode but keep results
-				m.search.isSearching = false
-				m.search.searchInput.Blur()
-				return m, nil
-			default:
-				// Update search input
-				m.search.searchInput, cmd = m.search.searchInput.Update(msg)
-				m.search.UpdateSearch(m.search.searchInput.Value(), m.emails)
-				m.updateTableRows()
-				return m, cmd
-			}
+	var out []email.Email
+	for _, e := range emails {
+		if members, ok := collapsed[e.UID]; ok {
+			out = append(out, members...)
+		} else {
+			out = append(out, e)
 		}
+	}
+	return out
+}
 
-		// Regular key handling
-		switch {
-		case key.Matches(msg, CommonKeys.Quit):
-			return m, tea.Quit
+// Helper function to find the threaded row under the table cursor
+func (m model) cursorThreadRow() (threadRow, bool) {
+	if !m.threads.enabled {
+		return threadRow{}, false
+	}
+	rows := m.threads.Rows(m.filteredEmails())
+	cursor := m.table.Cursor()
+	if cursor < 0 || cursor >= len(rows) {
+		return threadRow{}, false
+	}
+	return rows[cursor], true
+}
 
-		case key.Matches(msg, CommonKeys.Search):
-			if !m.viewingEmail {
-				m.search.ToggleSearch(m.emails)
-				m.updateTableRows()
-				return m, nil
+// Helper function to find the UID of the message under the table cursor
+func (m model) cursorUID() uint32 {
+	currentEmails := m.getCurrentEmails()
+	if cursor := m.table.Cursor(); cursor >= 0 && cursor < len(currentEmails) {
+		return currentEmails[cursor].UID
+	}
+	return 0
+}
+
+// Helper function to put the table cursor back on a message after the rows
+// were rebuilt, looking inside collapsed threads too
+func (m *model) setCursorUID(uid uint32) {
+	if uid == 0 {
+		return
+	}
+	if m.threads.enabled {
+		for i, row := range m.threads.Rows(m.filteredEmails()) {
+			for _, e := range row.members {
+				if e.UID == uid && (row.collapsed || row.email.UID == uid) {
+					m.table.SetCursor(i)
+					return
+				}
 			}
+		}
+		return
+	}
+	for i, e := range m.getCurrentEmails() {
+		if e.UID == uid {
+			m.table.SetCursor(i)
+			return
+		}
+	}
+}
 
-		case key.Matches(msg, CommonKeys.Select):
-			if !m.viewingEmail {
-				selectedRow := m.table.Cursor()
-				currentEmails := m.getCurrentEmails()
-				if selectedRow >= 0 && selectedRow < len(currentEmails) {
-					m.selectedEmail = currentEmails[selectedRow]
-					m.viewingEmail = true
+// Helper function to regroup the loaded messages into threads
+func (m *model) reloadThreads() tea.Cmd {
+	if !m.threads.enabled || len(m.emails) == 0 {
+		return nil
+	}
+	m.threads.loading = true
+	emails := append([]email.Email(nil), m.emails...)
+	return loadThreadsCmd(m.session, m.folders.current, emails)
+}
 
-					containerHeight := m.height - 6
-					headerHeight := 4
-					viewportHeight := containerHeight - headerHeight - 2
-					if viewportHeight < 5 {
-						viewportHeight = 5
-					}
+// Helper function to open a whole conversation, marking it read and
+// fetching the bodies that are not loaded yet
+func (m *model) openThread(members []email.Email) tea.Cmd {
+	m.thread = append([]email.Email(nil), members...)
+	m.viewingEmail = true
+	m.attachments = AttachmentState{}
+	m.allRecipients = false
+
+	// Replies and other actions apply to the newest message
+	m.selectedEmail = m.thread[0]
+	for _, e := range m.thread {
+		if e.Time.After(m.selectedEmail.Time) {
+			m.selectedEmail = e
+		}
+	}
+	m.bodyLoading = m.selectedEmail.Body == ""
+
+	var cmds []tea.Cmd
+	var unseen []uint32
+	for _, e := range m.thread {
+		delete(m.arrivals, e.UID)
+		if !e.Seen() {
+			unseen = append(unseen, e.UID)
+		}
+		if e.Body == "" {
+			cmds = append(cmds, loadBodyCmd(m.session, m.folders.current, e.UID))
+		}
+	}
+	if len(unseen) > 0 {
+		cmds = append(cmds, m.setFlag(unseen, imap.SeenFlag, true))
+	}
+
+	m.emailViewport = viewport.New(m.width-8, m.emailViewportHeight())
+	m.emailViewport.SetContent(conversationView(m.thread, m.width-14, m.search.Highlights("body")))
+	return tea.Batch(cmds...)
+}
+
+// Helper function to collect the UIDs of a set of messages
+func uidsOf(emails []email.Email) []uint32 {
+	uids := make([]uint32, 0, len(emails))
+	for _, e := range emails {
+		uids = append(uids, e.UID)
+	}
+	return uids
+}
+
+// Helper function to toggle a flag on a set of messages with one command.
+// A mixed set is switched on so that repeating the key switches it off.
+func (m *model) toggleFlag(emails []email.Email, flag string) tea.Cmd {
+	on := false
+	for _, e := range emails {
+		if !e.HasFlag(flag) {
+			on = true
+			break
+		}
+	}
+	return m.setFlag(uidsOf(emails), flag, on)
+}
+
+// Helper function to change a flag locally and store it on the server
+func (m *model) setFlag(uids []uint32, flag string, on bool) tea.Cmd {
+	m.applyFlag(uids, flag, on)
+	return setFlagCmd(m.session, m.folders.current, uids, flag, on)
+}
 
-					m.emailViewport = viewport.New(m.width-8, viewportHeight)
-					m.emailViewport.SetContent(m.selectedEmail.Body)
+// Helper function to update a flag on every local copy of the messages
+func (m *model) applyFlag(uids []uint32, flag string, on bool) {
+	targets := make(map[uint32]bool, len(uids))
+	for _, uid := range uids {
+		targets[uid] = true
+	}
+
+	changed := 0
+	update := func(list []email.Email, count bool) {
+		for i := range list {
+			if targets[list[i].UID] && list[i].HasFlag(flag) != on {
+				list[i].SetFlagLocal(flag, on)
+				if count {
+					changed++
 				}
 			}
+		}
+	}
+	update(m.emails, true)
+	update(m.search.originalEmails, false)
+	update(m.search.filteredEmails, false)
+	if targets[m.selectedEmail.UID] {
+		m.selectedEmail.SetFlagLocal(flag, on)
+	}
 
-		case key.Matches(msg, CommonKeys.Back):
-			if m.viewingEmail {
-				m.viewingEmail = false
+	if flag == imap.SeenFlag {
+		if on {
+			for uid := range targets {
+				delete(m.arrivals, uid)
 			}
+			m.folders.AdjustUnseen(m.folders.current, -changed)
+		} else {
+			m.folders.AdjustUnseen(m.folders.current, changed)
+		}
+	}
 
-		case key.Matches(msg, CommonKeys.Up):
-			if m.viewingEmail {
-				m.emailViewport.LineUp(1)
-				return m, nil
-			} else {
-				m.table, cmd = m.table.Update(msg)
-				return m, cmd
+	m.updateTableRows()
+}
+
+// Helper function to pick up new mail and flag changes made elsewhere
+func (m *model) refresh() tea.Cmd {
+	if len(m.emails) == 0 {
+		return m.loadMailbox(m.folders.current)
+	}
+	return tea.Batch(
+		loadNewEmailsCmd(m.session, m.folders.current, m.newestUID()),
+		syncFlagsCmd(m.session, m.folders.current, uidsOf(m.emails)),
+	)
+}
+
+// Helper function to replace local flags with the ones on the server
+func (m *model) syncFlags(flags map[uint32][]string) {
+	unseen := 0
+	update := func(list []email.Email, count bool) {
+		for i := range list {
+			f, ok := flags[list[i].UID]
+			if !ok {
+				continue
+			}
+			if count {
+				was, now := list[i].Seen(), email.Email{Flags: f}.Seen()
+				if was && !now {
+					unseen++
+				} else if !was && now {
+					unseen--
+				}
 			}
+			list[i].Flags = f
+		}
+	}
+	// New mail read elsewhere is no longer new
+	for uid, f := range flags {
+		if (email.Email{Flags: f}).Seen() {
+			delete(m.arrivals, uid)
+		}
+	}
+	update(m.emails, true)
+	update(m.search.originalEmails, false)
+	update(m.search.filteredEmails, false)
+	if f, ok := flags[m.selectedEmail.UID]; ok {
+		m.selectedEmail.Flags = f
+	}
+	m.folders.AdjustUnseen(m.folders.current, unseen)
 
-		case key.Matches(msg, CommonKeys.Down):
-			if m.viewingEmail {
-				m.emailViewport.LineDown(1)
-				return m, nil
-			} else {
-				m.table, cmd = m.table.Update(msg)
-				return m, cmd
+	cursor := m.table.Cursor()
+	m.updateTableRows()
+	if rows := len(m.getCurrentEmails()); cursor >= rows && rows > 0 {
+		m.table.SetCursor(rows - 1)
+	}
+}
+
+// Helper function to handle the answer to deleting messages for good. Any
+// key but yes keeps them.
+func (m *model) updateConfirmDelete(msg tea.KeyMsg) tea.Cmd {
+	uids := m.confirmDelete
+	m.confirmDelete = nil
+
+	switch {
+	case key.Matches(msg, ConfirmKeys.Quit):
+		return tea.Quit
+	case key.Matches(msg, ConfirmKeys.Yes):
+		m.status = ""
+		m.removeLocal(uids)
+		return deleteCmd(m.session, m.folders.current, uids)
+	}
+	m.status = "Nothing was deleted"
+	return nil
+}
+
+// Helper function to open the folder picker for moving messages
+func (m *model) openPicker(uids []uint32) {
+	var choices []string
+	for _, mb := range m.folders.mailboxes {
+		if mb.Name != m.folders.current {
+			choices = append(choices, mb.Name)
+		}
+	}
+	m.picker = OpenPicker(choices, uids)
+}
+
+// Helper function to handle keys while the folder picker is open
+func (m *model) updatePicker(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return tea.Quit
+	case tea.KeyEscape:
+		m.picker.active = false
+		return nil
+	case tea.KeyUp, tea.KeyCtrlP:
+		m.picker.CursorUp()
+		return nil
+	case tea.KeyDown, tea.KeyCtrlN:
+		m.picker.CursorDown()
+		return nil
+	case tea.KeyEnter:
+		dest := m.picker.Selected()
+		if dest == "" {
+			return nil
+		}
+		m.picker.active = false
+		uids := m.picker.uids
+		m.removeLocal(uids)
+		return moveCmd(m.session, m.folders.current, uids, dest)
+	}
+
+	var cmd tea.Cmd
+	m.picker.input, cmd = m.picker.input.Update(msg)
+	m.picker.filter()
+	return cmd
+}
+
+// Helper function to drop messages from every local list once they have
+// been moved out of the current mailbox
+func (m *model) removeLocal(uids []uint32) {
+	targets := make(map[uint32]bool, len(uids))
+	for _, uid := range uids {
+		targets[uid] = true
+	}
+
+	var kept []email.Email
+	unseen := 0
+	for _, e := range m.emails {
+		if targets[e.UID] {
+			if !e.Seen() {
+				unseen++
 			}
+			continue
 		}
+		kept = append(kept, e)
 	}
+	m.emails = kept
 
-	if !m.viewingEmail && !m.search.isSearching {
-		m.table, cmd = m.table.Update(msg)
+	filter := func(list []email.Email) []email.Email {
+		var out []email.Email
+		for _, e := range list {
+			if !targets[e.UID] {
+				out = append(out, e)
+			}
+		}
+		return out
 	}
+	m.search.originalEmails = filter(m.search.originalEmails)
+	m.search.filteredEmails = filter(m.search.filteredEmails)
 
-	return m, cmd
+	if m.viewingEmail && targets[m.selectedEmail.UID] {
+		m.viewingEmail = false
+	}
+	for uid := range targets {
+		delete(m.arrivals, uid)
+	}
+	m.folders.AdjustUnseen(m.folders.current, -unseen)
+
+	cursor := m.table.Cursor()
+	m.updateTableRows()
+	if rows := len(m.getCurrentEmails()); cursor >= rows && rows > 0 {
+		m.table.SetCursor(rows - 1)
+	}
 }
 
-// Helper function to get current emails (filtered or all)
-func (m model) getCurrentEmails() []email.Email {
+// Helper function to fetch the next page of older messages
+func (m *model) loadMore() tea.Cmd {
+	if m.loading || m.loadingMore || m.search.isSearching {
+		return nil
+	}
+
+	oldest := m.oldestUID()
+	if m.reachedOldest || oldest <= 1 {
+		m.status = "No older messages"
+		return nil
+	}
+
+	m.loadingMore = true
+	m.status = ""
+	return tea.Batch(m.spinner.Tick, loadOlderEmailsCmd(m.session, m.folders.current, oldest))
+}
+
+// Helper function to page in older messages when the cursor nears the bottom
+func (m *model) maybeLoadMore() tea.Cmd {
+	rows := len(m.getCurrentEmails())
+	if rows == 0 || m.table.Cursor() < rows-3 {
+		return nil
+	}
+	if m.reachedOldest || m.oldestUID() <= 1 {
+		return nil
+	}
+	return m.loadMore()
+}
+
+// Helper function to find the lowest UID currently loaded
+func (m model) oldestUID() uint32 {
+	var oldest uint32
+	for _, e := range m.emails {
+		if oldest == 0 || e.UID < oldest {
+			oldest = e.UID
+		}
+	}
+	return oldest
+}
+
+// Helper function to switch the table to freshly loaded messages
+func (m *model) showMailbox(name string, emails []email.Email) tea.Cmd {
+	// Leave search mode so the new mailbox is shown unfiltered
 	if m.search.isSearching {
-		return m.search.filteredEmails
+		m.search.ToggleSearch(m.emails)
+	}
+
+	changed := name != m.folders.current || m.watcher == nil
+
+	// UIDs from before a UIDVALIDITY change name other messages now. Other
+	// mailboxes have their own UIDVALIDITY, so only a reload counts.
+	resynced := name == m.folders.current && len(emails) > 0 && len(m.emails) > 0 &&
+		emails[0].UIDValidity != m.emails[0].UIDValidity
+	if resynced {
+		m.selection.Clear()
+		m.arrivals = make(map[uint32]bool)
+		m.viewingEmail = false
+		m.status = "Mailbox changed on the server and was reloaded"
+	}
+
+	if name != m.folders.current || resynced {
+		m.selection.Clear()
+		m.flaggedOnly = false
+		m.threads.Reset()
+	}
+
+	m.emails = emails
+	m.folders.current = name
+	m.reachedOldest = len(emails) < pageSize
+	if !resynced {
+		m.status = ""
+	}
+	m.updateTableRows()
+	m.table.SetCursor(0)
+
+	if !changed {
+		return m.reloadThreads()
+	}
+
+	// Watch the newly opened mailbox for incoming mail
+	m.arrivals = make(map[uint32]bool)
+	m.watcher = m.session.Watch(name)
+	return tea.Batch(waitForMailCmd(m.watcher), m.reloadThreads())
+}
+
+// Helper function to put newly arrived messages at the top of the table
+func (m *model) addArrivals(emails []email.Email) {
+	known := make(map[uint32]bool, len(m.emails))
+	for _, e := range m.emails {
+		known[e.UID] = true
+	}
+
+	var fresh []email.Email
+	unseen := 0
+	for _, e := range emails {
+		if !known[e.UID] {
+			fresh = append(fresh, e)
+			// Mail read elsewhere or a copy of our own can arrive already seen
+			if !e.Seen() {
+				m.arrivals[e.UID] = true
+				unseen++
+			}
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+
+	uid := m.cursorUID()
+	m.emails = append(fresh, m.emails...)
+	m.folders.AddArrivals(m.folders.current, len(fresh), unseen)
+
+	// Keep the cursor on the message it was on
+	if m.threads.enabled {
+		m.updateTableRows()
+		m.setCursorUID(uid)
+		return
+	}
+	cursor := m.table.Cursor()
+	m.updateTableRows()
+	if !m.search.isSearching {
+		m.table.SetCursor(cursor + len(fresh))
+		m.selection.anchor += len(fresh)
+		m.updateTableRows()
+	}
+}
+
+// Helper function to find the highest UID currently loaded
+func (m model) newestUID() uint32 {
+	var newest uint32
+	for _, e := range m.emails {
+		if e.UID > newest {
+			newest = e.UID
+		}
 	}
-	return m.emails
+	return newest
 }
 
 // Helper function to update table rows
 func (m *model) updateTableRows() {
 	currentEmails := m.getCurrentEmails()
+	var threadRows []threadRow
+	if m.threads.enabled {
+		threadRows = m.threads.Rows(m.filteredEmails())
+	}
 	var rows []table.Row
 
-	for _, e := range currentEmails {
+	cursor := m.table.Cursor()
+	for i, e := range currentEmails {
 		datePart := ""
 		timePart := ""
 		if len(e.Date) >= 10 {
@@ -168,11 +1656,40 @@ func (m *model) updateTableRows() {
 			timePart = e.Date[11:16]
 		}
 
+		marker := " "
+		if m.selection.Marked(i, cursor, e.UID) {
+			marker = markedMarker
+		}
+		unread, flagged, subject := !e.Seen(), e.Flagged(), e.Subject
+
+		// A collapsed thread shows whether any of its messages is unread
+		// or flagged
+		if threadRows != nil {
+			row := threadRows[i]
+			subject = threadSubject(row)
+			if row.collapsed {
+				for _, member := range row.members {
+					unread = unread || !member.Seen()
+					flagged = flagged || member.Flagged()
+				}
+			}
+		}
+
+		if unread {
+			marker += unreadMarker
+		}
+		star := ""
+		if flagged {
+			star = starMarker
+		}
+
 		rows = append(rows, table.Row{
-			e.From,
+			marker,
+			star,
+			senderColumn(e.From, m.session.Config().SenderNameOnly),
 			datePart,
 			timePart,
-			e.Subject,
+			subject,
 		})
 	}
 
@@ -180,31 +1697,26 @@ func (m *model) updateTableRows() {
 }
 
 func (m model) View() string {
+	if m.composing {
+		composeView := m.compose.View(m.width, m.height, m.spinner.View())
+		if m.filePicker.active {
+			pickerView := m.filePicker.View(m.width - 12)
+			return lipgloss.JoinVertical(lipgloss.Center, pickerView, composeView, m.helpView())
+		}
+		return lipgloss.JoinVertical(lipgloss.Center, composeView, m.helpView())
+	}
+
 	if m.viewingEmail {
 		containerHeight := m.height - 6
 
-		headerContent := fmt.Sprintf(
-			"From: %s\nDate: %s\nTime: %s\nSubject: %s\n",
-			m.selectedEmail.From,
-			func() string {
-				if len(m.selectedEmail.Date) >= 10 {
-					return m.selectedEmail.Date[:10]
-				}
-				return m.selectedEmail.Date
-			}(),
-			func() string {
-				if len(m.selectedEmail.Date) >= 16 {
-					return m.selectedEmail.Date[11:16]
-				}
-				return ""
-			}(),
-			m.selectedEmail.Subject,
-		)
-
-		headerView := lipgloss.NewStyle().
-			Bold(true).
-			Padding(0, 0, 1, 0).
-			Render(headerContent)
+		headerView := m.messageHeaderView()
+
+		if atts := m.selectedEmail.Attachments; m.thread == nil && len(atts) > 0 {
+			attachmentView := lipgloss.NewStyle().
+				Padding(0, 0, 1, 0).
+				Render(m.attachments.View(atts, m.width-14))
+			headerView = lipgloss.JoinVertical(lipgloss.Left, headerView, attachmentView)
+		}
 
 		emailBodyView := m.emailViewport.View()
 
@@ -216,8 +1728,11 @@ func (m model) View() string {
 			Height(containerHeight).
 			Render(lipgloss.JoinVertical(lipgloss.Left, headerView, emailBodyView))
 
-		helpView := CommonHelp.View(CommonKeys)
+		helpView := m.helpView()
 
+		if m.status != "" {
+			return lipgloss.JoinVertical(lipgloss.Center, emailView, m.status, helpView)
+		}
 		return lipgloss.JoinVertical(lipgloss.Center, emailView, helpView)
 	}
 
@@ -227,7 +1742,7 @@ func (m model) View() string {
 		BorderForeground(lipgloss.Color(styles.Green)).
 		Padding(0, 1)
 
-	helpView := CommonHelp.View(CommonKeys)
+	helpView := m.helpView()
 
 	// Build the view components
 	var viewComponents []string
@@ -237,16 +1752,133 @@ func (m model) View() string {
 		viewComponents = append(viewComponents, searchBar)
 	}
 
-	// Add table
-	bordered := tableView.Render(m.table.View())
+	// Add folder picker if moving messages
+	if m.picker.active {
+		viewComponents = append(viewComponents, m.picker.View(m.width-20-sidebarWidth))
+	}
+
+	// Add loading status line
+	if m.loading {
+		viewComponents = append(viewComponents,
+			fmt.Sprintf("%s Loading %s...", m.spinner.View(), m.loadingMailbox))
+	} else if m.loadingMore {
+		viewComponents = append(viewComponents,
+			fmt.Sprintf("%s Loading older messages...", m.spinner.View()))
+	} else if m.status != "" {
+		viewComponents = append(viewComponents, m.status)
+	}
+
+	// Add folder sidebar and table, or the error panel if loading failed
+	var bordered string
+	if m.loadErr != nil {
+		bordered = m.renderErrorPanel()
+	} else {
+		bordered = tableView.Render(highlightTable(m.table, selectedRowStyle,
+			m.search.Highlights("from"), m.search.Highlights("subject")))
+	}
+	sidebar := m.folders.RenderSidebar(lipgloss.Height(bordered) - 2)
 	padded := lipgloss.NewStyle().
 		Padding(2, 4).
-		Render(bordered)
+		Render(lipgloss.JoinHorizontal(lipgloss.Top, sidebar, bordered))
 
 	viewComponents = append(viewComponents, padded)
 
+	// Add status bar
+	viewComponents = append(viewComponents, m.renderStatusBar())
+
 	// Add help
 	viewComponents = append(viewComponents, helpView)
 
 	return lipgloss.JoinVertical(lipgloss.Center, viewComponents...)
 }
+
+// Helper function to render the load error in place of the table
+func (m model) renderErrorPanel() string {
+	content := fmt.Sprintf("%s\n\nPress %s to retry",
+		m.loadErr.Error(), CommonKeys.Retry.Help().Key)
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color(styles.Red)).
+		Foreground(lipgloss.Color(styles.Red)).
+		Padding(1, 2).
+		Width(m.width - 20 - sidebarWidth).
+		Render(content)
+}
+
+// Helper function to render help for the keys that apply right now
+func (m model) helpView() string {
+	// Only the main keys have a full help worth expanding
+	if m.composing {
+		return CommonHelp.ShortHelpView(ComposeKeys.ShortHelp())
+	}
+	if m.search.isSearching && m.search.searchInput.Focused() && !m.viewingEmail {
+		return CommonHelp.ShortHelpView(SearchKeys.ShortHelp())
+	}
+	if m.viewingEmail && m.attachments.active {
+		return CommonHelp.ShortHelpView(AttachmentKeys.ShortHelp())
+	}
+	if m.confirmDelete != nil {
+		return CommonHelp.ShortHelpView(ConfirmKeys.ShortHelp())
+	}
+
+	keys := CommonKeys
+	keys.Retry.SetEnabled(m.loadErr != nil && !m.viewingEmail)
+	keys.Back.SetEnabled(m.viewingEmail)
+	if CommonHelp.ShowAll {
+		keys.Help.SetHelp("?", "fewer keys")
+	}
+	keys.LoadMore.SetEnabled(!m.viewingEmail)
+	keys.Reply.SetEnabled(m.viewingEmail && !m.bodyLoading)
+	keys.ReplyAll.SetEnabled(m.viewingEmail && !m.bodyLoading)
+	keys.Forward.SetEnabled(m.viewingEmail && !m.bodyLoading)
+	keys.Attachments.SetEnabled(m.viewingEmail && m.thread == nil && len(m.selectedEmail.Attachments) > 0)
+	keys.Recipients.SetEnabled(m.viewingEmail && m.thread == nil && hasLongRecipients(m.selectedEmail))
+	keys.Threads.SetEnabled(!m.viewingEmail)
+	keys.Fold.SetEnabled(!m.viewingEmail && m.threads.enabled)
+	keys.Flagged.SetEnabled(!m.viewingEmail)
+	keys.Mark.SetEnabled(!m.viewingEmail)
+	keys.Visual.SetEnabled(!m.viewingEmail)
+	keys.Unmark.SetEnabled(!m.viewingEmail && m.selection.Active())
+	return CommonHelp.View(keys)
+}
+
+// Helper function to render the mailbox status bar
+func (m model) renderStatusBar() string {
+	parts := []string{
+		m.folders.current,
+		fmt.Sprintf("%d messages", len(m.emails)),
+	}
+	if m.flaggedOnly {
+		parts = append(parts, fmt.Sprintf("%s %d flagged", starMarker, len(m.filteredEmails())))
+	}
+	if m.threads.enabled {
+		threads := make(map[uint32]bool)
+		for _, row := range m.threads.Rows(m.filteredEmails()) {
+			threads[row.key] = true
+		}
+		label := fmt.Sprintf("%d threads", len(threads))
+		if m.threads.loading {
+			label += " (threading...)"
+		}
+		parts = append(parts, label)
+	}
+	if m.selection.Active() {
+		marked := fmt.Sprintf("%d marked", m.selection.Count(m.getCurrentEmails(), m.table.Cursor()))
+		if m.selection.visual {
+			marked += " (visual)"
+		}
+		parts = append(parts, lipgloss.NewStyle().
+			Foreground(lipgloss.Color(styles.Green)).
+			Bold(true).
+			Render(marked))
+	}
+	if n := len(m.arrivals); n > 0 {
+		parts = append(parts, lipgloss.NewStyle().
+			Foreground(lipgloss.Color(styles.Green)).
+			Bold(true).
+			Render(fmt.Sprintf("✉ %d new", n)))
+	}
+
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/Zachkp/GoMail/config"
+	"github.com/Zachkp/GoMail/email"
+	"github.com/emersion/go-imap"
+)
+
+// Helper function to build a model without connecting to a server
+func testModel(t *testing.T) model {
+	session := email.NewSession(&config.Config{})
+	t.Cleanup(func() { session.Close() })
+	return CreateTable(session)
+}
+
+// Helper function to make a page of messages in a mailbox
+func testMailbox(mailbox string, validity uint32, uids ...uint32) []email.Email {
+	var emails []email.Email
+	for _, uid := range uids {
+		emails = append(emails, email.Email{Mailbox: mailbox, UIDValidity: validity, UID: uid})
+	}
+	return emails
+}
+
+func TestShowMailbox(t *testing.T) {
+	const resyncStatus = "Mailbox changed on the server and was reloaded"
+
+	tests := []struct {
+		name       string
+		mailbox    string
+		validity   uint32
+		wantStatus string
+		wantMarked bool
+	}{
+		{name: "refresh", mailbox: "INBOX", validity: 1, wantStatus: "", wantMarked: true},
+		{name: "folder switch", mailbox: "Archive", validity: 7, wantStatus: "", wantMarked: false},
+		{name: "UIDVALIDITY change", mailbox: "INBOX", validity: 2, wantStatus: resyncStatus, wantMarked: false},
+	}
+
+	for _, tt := range tests {
+		m := testModel(t)
+		m.showMailbox("INBOX", testMailbox("INBOX", 1, 3, 2, 1))
+		m.selection.Toggle(2)
+
+		m.showMailbox(tt.mailbox, testMailbox(tt.mailbox, tt.validity, 3, 2, 1))
+		if m.status != tt.wantStatus {
+			t.Errorf("%s: status = %q, want %q", tt.name, m.status, tt.wantStatus)
+		}
+		// Marks name messages of the mailbox they were made in
+		if got := m.selection.marked[2]; got != tt.wantMarked {
+			t.Errorf("%s: marked = %v, want %v", tt.name, got, tt.wantMarked)
+		}
+	}
+}
+
+func TestArrivals(t *testing.T) {
+	m := testModel(t)
+	m.showMailbox("INBOX", testMailbox("INBOX", 1, 2, 1))
+
+	// A copy of our own sent mail arrives already read
+	arrived := testMailbox("INBOX", 1, 5, 4, 3)
+	arrived[2].Flags = []string{imap.SeenFlag}
+	m.addArrivals(arrived)
+	if got := len(m.arrivals); got != 2 {
+		t.Fatalf("after arrival: %d new, want 2", got)
+	}
+
+	m.applyFlag([]uint32{5}, imap.SeenFlag, true)
+	if got := len(m.arrivals); got != 1 {
+		t.Fatalf("after reading: %d new, want 1", got)
+	}
+
+	m.syncFlags(map[uint32][]string{4: {imap.SeenFlag}})
+	if got := len(m.arrivals); got != 0 {
+		t.Fatalf("after reading elsewhere: %d new, want 0", got)
+	}
+}
@@ -0,0 +1,41 @@
+// models/picker.go
+package models
+
+import "strings"
+
+// PickerState is a fuzzy folder picker used to choose a move destination
+type PickerState struct {
+	FuzzyList
+	active  bool
+	choices []string
+
+	// Messages the chosen folder applies to
+	uids []uint32
+}
+
+// Open the picker over the given folders for a set of messages
+func OpenPicker(choices []string, uids []uint32) PickerState {
+	p := PickerState{
+		FuzzyList: NewFuzzyList("Move to folder...", 100),
+		active:    true,
+		choices:   choices,
+		uids:      uids,
+	}
+	p.filter()
+
+	return p
+}
+
+// Refilter the folders after the query changed
+func (p *PickerState) filter() {
+	p.match(strings.TrimSpace(p.input.Value()), p.choices)
+}
+
+// Render folder picker
+func (p *PickerState) View(width int) string {
+	note := ""
+	if len(p.matches) == 0 {
+		note = "No matching folders"
+	}
+	return p.FuzzyList.View(width, note)
+}
@@ -2,12 +2,15 @@
 package models
 
 import (
+	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/Zachkp/GoMail/email"
+	"github.com/Zachkp/GoMail/fzf"
+	"github.com/Zachkp/GoMail/styles"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/lithammer/fuzzysearch/fuzzy"
 )
 
 // Add these fields to your existing model struct in models.go
@@ -16,12 +19,33 @@ type SearchState struct {
 	searchInput    textinput.Model
 	originalEmails []email.Email
 	filteredEmails []email.Email
+
+	// Server mode sends the query to the server as a UID SEARCH over the
+	// whole mailbox instead of filtering the loaded messages
+	server  bool
+	pending bool
+	query   string
+	total   int
+	err     error
+
+	// The last query that parsed, whose words are highlighted
+	parsed *email.Query
+
+	// The field words without one of their own are limited to, or "" for
+	// the sender, subject and body
+	field string
 }
 
+// Placeholders for the two search modes
+const (
+	localPlaceholder  = "Search emails: alice is:unread has:attachment"
+	serverPlaceholder = `Search the server: from:alice subject:"report" after:2024-01-31`
+)
+
 // Initialize search functionality
 func InitSearch() SearchState {
 	ti := textinput.New()
-	ti.Placeholder = "Search emails..."
+	ti.Placeholder = localPlaceholder
 	ti.CharLimit = 100
 	ti.Width = 50
 
@@ -33,51 +57,59 @@ func InitSearch() SearchState {
 	}
 }
 
-// Simple fuzzy search function (in case lithammer doesn't work)
-func simpleFuzzyMatch(query, target string) bool {
-	query = strings.ToLower(strings.TrimSpace(query))
-	target = strings.ToLower(target)
-
-	if query == "" {
-		return true
-	}
-
-	// Simple substring matching for now
-	return strings.Contains(target, query)
-}
-
-// Update search input and filter results
+// Update search input and filter results, best match first. A query that
+// does not parse leaves the last results in place and shows the error
+// under the bar.
 func (s *SearchState) UpdateSearch(value string, allEmails []email.Email) {
-	if value == "" {
+	q, err := email.ParseQueryIn(value, s.field)
+	s.err = err
+	if err != nil {
+		return
+	}
+	if strings.TrimSpace(value) == "" {
+		s.parsed = nil
 		s.filteredEmails = s.originalEmails
 		return
 	}
 
-	var results []email.Email
-	query := strings.ToLower(strings.TrimSpace(value))
+	s.parsed = q
+	s.filteredEmails = resultEmails(fzf.Rank(s.originalEmails, q))
+}
 
-	for _, email := range s.originalEmails {
-		searchTarget := strings.ToLower(email.From + " " + email.Subject + " " + email.Body)
+// Helper function to take the messages from ranked search results
+func resultEmails(results []fzf.Result) []email.Email {
+	emails := make([]email.Email, len(results))
+	for i, r := range results {
+		emails[i] = r.Email
+	}
+	return emails
+}
 
-		// Try fuzzy search first, fallback to simple matching
-		var matches bool
+// Highlights lists the words of the active search to highlight in a field
+// of a message
+func (s *SearchState) Highlights(field string) []string {
+	if !s.isSearching || s.parsed == nil {
+		return nil
+	}
+	return fzf.Patterns(s.parsed, field)
+}
 
-		// Use fuzzy search if available
-		searchTargets := []string{searchTarget}
-		fuzzyMatches := fuzzy.RankFindFold(query, searchTargets)
-		if len(fuzzyMatches) > 0 && fuzzyMatches[0].Distance <= len(query)*2 {
-			matches = true
-		} else {
-			// Fallback to simple substring matching
-			matches = simpleFuzzyMatch(query, searchTarget)
-		}
+// Check the query typed for a server search so mistakes show up before it
+// is sent
+func (s *SearchState) CheckQuery() {
+	_, s.err = email.ParseQueryIn(s.searchInput.Value(), s.field)
+}
 
-		if matches {
-			results = append(results, email)
-		}
+// Limit words without a field of their own to the next field in turn
+func (s *SearchState) CycleField() {
+	i := slices.Index(fzf.Fields, s.field)
+	s.field = fzf.Fields[(i+1)%len(fzf.Fields)]
+	if s.server {
+		s.setServer(true)
+		s.CheckQuery()
+	} else {
+		s.UpdateSearch(s.searchInput.Value(), s.originalEmails)
 	}
-
-	s.filteredEmails = results
 }
 
 // Toggle search mode
@@ -96,7 +128,69 @@ func (s *SearchState) ToggleSearch(emails []email.Email) {
 		s.searchInput.SetValue("")
 		s.filteredEmails = s.originalEmails
 		s.isSearching = false
+		s.field = ""
+		s.setServer(false)
+	}
+}
+
+// Switch between filtering the loaded messages and searching the server
+func (s *SearchState) ToggleServer() {
+	s.setServer(!s.server)
+	if !s.server {
+		s.UpdateSearch(s.searchInput.Value(), s.originalEmails)
+	}
+}
+
+func (s *SearchState) setServer(on bool) {
+	s.server = on
+	s.pending = false
+	s.query = ""
+	s.total = 0
+	s.err = nil
+	s.parsed = nil
+	s.filteredEmails = s.originalEmails
+	if on {
+		s.searchInput.Placeholder = serverPlaceholder
+	} else {
+		s.searchInput.Placeholder = localPlaceholder
+	}
+}
+
+// Start a server search for the current query, returning it, or "" if
+// there is nothing to search for
+func (s *SearchState) StartServerSearch() string {
+	query := strings.TrimSpace(s.searchInput.Value())
+	if query == "" {
+		s.filteredEmails = s.originalEmails
+		return ""
+	}
+	// Gmail may understand a query the parser rejects, so without a field
+	// the server gets the last word; it reports the error otherwise
+	q, err := email.ParseQueryIn(query, s.field)
+	if err != nil && s.field != "" {
+		s.err = err
+		return ""
 	}
+	s.parsed = q
+	s.pending = true
+	s.query = query
+	s.err = nil
+	return query
+}
+
+// Show the results of a server search best match first, ignoring those of
+// an older query
+func (s *SearchState) SetServerResults(query, field string, emails []email.Email, total int, err error) bool {
+	if !s.server || query != s.query || field != s.field {
+		return false
+	}
+	s.pending = false
+	s.err = err
+	s.total = total
+	if err == nil {
+		s.filteredEmails = resultEmails(fzf.Order(emails, s.parsed))
+	}
+	return true
 }
 
 // Render search bar
@@ -111,5 +205,31 @@ func (s *SearchState) RenderSearchBar() string {
 		Padding(0, 1).
 		Margin(0, 0, 1, 0)
 
-	return searchStyle.Render("🔍 " + s.searchInput.View())
+	icon := "🔍 "
+	if s.server {
+		icon = "🌐 "
+	}
+	bar := icon
+	if s.field != "" {
+		bar += lipgloss.NewStyle().
+			Foreground(lipgloss.Color(styles.Green)).
+			Render("in "+s.field) + " "
+	}
+	bar += s.searchInput.View()
+
+	switch {
+	case s.err != nil:
+		bar += "\n" + lipgloss.NewStyle().
+			Foreground(lipgloss.Color(styles.Red)).
+			Render(s.err.Error())
+	case !s.server:
+	case s.pending:
+		bar += "\n" + fmt.Sprintf("Searching the server for %q...", s.query)
+	case s.query != "" && s.total > len(s.filteredEmails):
+		bar += "\n" + fmt.Sprintf("Showing the newest %d of %d matches", len(s.filteredEmails), s.total)
+	case s.query != "":
+		bar += "\n" + fmt.Sprintf("%d matches on the server", s.total)
+	}
+
+	return searchStyle.Render(bar)
 }
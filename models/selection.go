@@ -0,0 +1,92 @@
+// models/selection.go
+package models
+
+import "github.com/Zachkp/GoMail/email"
+
+// Marker shown in the status column for marked messages
+const markedMarker = "▌"
+
+// SelectionState tracks the messages marked for a bulk action. Marks are kept
+// by UID so they survive filtering and new mail shifting the rows around.
+type SelectionState struct {
+	marked map[uint32]bool
+
+	// Visual range from the anchor row to the table cursor
+	visual bool
+	anchor int
+}
+
+// Toggle the mark on one message
+func (s *SelectionState) Toggle(uid uint32) {
+	if s.marked == nil {
+		s.marked = make(map[uint32]bool)
+	}
+	if s.marked[uid] {
+		delete(s.marked, uid)
+	} else {
+		s.marked[uid] = true
+	}
+}
+
+// Start a visual range at the given row
+func (s *SelectionState) StartVisual(row int) {
+	s.visual = true
+	s.anchor = row
+}
+
+// End the visual range, marking every message inside it
+func (s *SelectionState) EndVisual(emails []email.Email, cursor int) {
+	if s.marked == nil {
+		s.marked = make(map[uint32]bool)
+	}
+	for i, e := range emails {
+		if s.inRange(i, cursor) {
+			s.marked[e.UID] = true
+		}
+	}
+	s.visual = false
+}
+
+// Drop every mark and any visual range
+func (s *SelectionState) Clear() {
+	s.marked = nil
+	s.visual = false
+}
+
+// Active reports whether anything is marked or a range is being selected
+func (s SelectionState) Active() bool {
+	return len(s.marked) > 0 || s.visual
+}
+
+// Marked reports whether the message on the given row is part of the selection
+func (s SelectionState) Marked(row, cursor int, uid uint32) bool {
+	return s.marked[uid] || s.inRange(row, cursor)
+}
+
+// Selected returns the marked messages in table order
+func (s SelectionState) Selected(emails []email.Email, cursor int) []email.Email {
+	var selected []email.Email
+	for i, e := range emails {
+		if s.Marked(i, cursor, e.UID) {
+			selected = append(selected, e)
+		}
+	}
+	return selected
+}
+
+// Count of messages in the selection
+func (s SelectionState) Count(emails []email.Email, cursor int) int {
+	return len(s.Selected(emails, cursor))
+}
+
+// Helper function to check whether a row lies in the visual range
+func (s SelectionState) inRange(row, cursor int) bool {
+	if !s.visual {
+		return false
+	}
+	lo, hi := s.anchor, cursor
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	return row >= lo && row <= hi
+}
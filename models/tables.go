@@ -2,21 +2,42 @@
 package models
 
 import (
-	"log"
-
 	"github.com/Zachkp/GoMail/email"
 	"github.com/Zachkp/GoMail/styles"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/lipgloss"
 )
 
+// Markers shown in the status and star columns
+const (
+	unreadMarker = "●"
+	starMarker   = "★"
+)
+
+// Indexes of the columns that search words are highlighted in
+const (
+	senderCol  = 2
+	subjectCol = 5
+)
+
+// Style of the row under the cursor
+var selectedRowStyle = table.DefaultStyles().Selected.
+	Foreground(lipgloss.Color(styles.White)).
+	Background(lipgloss.Color(styles.DarkGray)).
+	Bold(true)
+
 func CreateColumns(width int) []table.Column {
+	statusWidth := 3
+	starWidth := 2
 	senderWidth := 30
 	dateWidth := 10
 	timeWidth := 10
-	subjectWidth := width - senderWidth - dateWidth - timeWidth
+	subjectWidth := width - statusWidth - starWidth - senderWidth - dateWidth - timeWidth
 
 	return []table.Column{
+		{Title: "", Width: statusWidth},
+		{Title: starMarker, Width: starWidth},
 		{Title: "Sender", Width: senderWidth},
 		{Title: "Date", Width: dateWidth},
 		{Title: "Time", Width: timeWidth},
@@ -24,62 +45,42 @@ func CreateColumns(width int) []table.Column {
 	}
 }
 
-func CreateTable() model {
+func CreateTable(session *email.Session) model {
 	columns := CreateColumns(styles.PlaceholderWidth)
 
-	emails, err := email.FetchLatestEmails(25)
-	if err != nil {
-		log.Printf("Error fetching emails: %v", err)
-		emails = []email.Email{}
-	}
-
-	var rows []table.Row
-	for _, e := range emails {
-		datePart := ""
-		timePart := ""
-		if len(e.Date) >= 10 {
-			datePart = e.Date[:10]
-		}
-		if len(e.Date) >= 16 {
-			timePart = e.Date[11:16]
-		}
-
-		rows = append(rows, table.Row{
-			e.From,
-			datePart,
-			timePart,
-			e.Subject,
-		})
-	}
-
 	t := table.New(
 		table.WithColumns(columns),
-		table.WithRows(rows),
 		table.WithFocused(true),
 		table.WithHeight(10),
 	)
 
-	// Initialize search state
-	searchState := InitSearch()
-
-	m := model{
-		table:  t,
-		width:  styles.PlaceholderWidth,
-		emails: emails,
-		search: searchState,
-	}
-
 	s := table.DefaultStyles()
 	s.Header = s.Header.
 		BorderStyle(lipgloss.ThickBorder()).
 		BorderForeground(lipgloss.Color(styles.Green)).
 		BorderBottom(true).
 		Bold(true)
-	s.Selected = s.Selected.
-		Foreground(lipgloss.Color(styles.White)).
-		Background(lipgloss.Color(styles.DarkGray)).
-		Bold(true)
+	s.Selected = selectedRowStyle
 	t.SetStyles(s)
 
+	// Initialize search state
+	searchState := InitSearch()
+
+	// Spinner shown while mail loads in the background
+	sp := spinner.New()
+	sp.Spinner = spinner.Dot
+	sp.Style = lipgloss.NewStyle().Foreground(lipgloss.Color(styles.Green))
+
+	m := model{
+		session:        session,
+		table:          t,
+		width:          styles.PlaceholderWidth,
+		search:         searchState,
+		folders:        InitFolders(nil),
+		loading:        true,
+		loadingMailbox: email.DefaultMailbox,
+		spinner:        sp,
+	}
+
 	return m
 }
@@ -0,0 +1,225 @@
+// models/threads.go
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Zachkp/GoMail/email"
+	"github.com/Zachkp/GoMail/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ThreadState groups the message list into conversations. Threads are
+// collapsed to their newest message until expanded, and are keyed by the
+// UID of their first message.
+type ThreadState struct {
+	enabled  bool
+	loading  bool
+	roots    []*email.ThreadNode
+	expanded map[uint32]bool
+}
+
+// threadRow is one line of the threaded table: a single message, or a whole
+// collapsed thread shown by its newest message
+type threadRow struct {
+	email     email.Email
+	depth     int
+	key       uint32
+	subject   string
+	members   []email.Email
+	collapsed bool
+}
+
+// Toggle thread mode, forgetting which threads were expanded
+func (t *ThreadState) Toggle() {
+	t.enabled = !t.enabled
+	t.expanded = make(map[uint32]bool)
+}
+
+// Reset the threads when another mailbox is shown
+func (t *ThreadState) Reset() {
+	t.roots = nil
+	t.loading = false
+	t.expanded = make(map[uint32]bool)
+}
+
+// Expand or collapse the thread with the given key
+func (t *ThreadState) Fold(key uint32) {
+	if t.expanded == nil {
+		t.expanded = make(map[uint32]bool)
+	}
+	t.expanded[key] = !t.expanded[key]
+}
+
+// Rows builds the table lines for a list of messages. Messages the thread
+// trees do not know yet, such as new arrivals, are threads of their own.
+// Threads are ordered by their newest message, and replies by date.
+func (t *ThreadState) Rows(list []email.Email) []threadRow {
+	byUID := make(map[uint32]email.Email, len(list))
+	for _, e := range list {
+		byUID[e.UID] = e
+	}
+
+	type thread struct {
+		members []email.Email
+		depths  []int
+		newest  email.Email
+	}
+
+	var threads []thread
+	covered := make(map[uint32]bool, len(list))
+
+	for _, root := range t.roots {
+		var th thread
+		var walk func(n *email.ThreadNode, depth int)
+		walk = func(n *email.ThreadNode, depth int) {
+			e, ok := byUID[n.UID]
+			if ok && !covered[n.UID] {
+				covered[n.UID] = true
+				th.members = append(th.members, e)
+				th.depths = append(th.depths, depth)
+				if e.Time.After(th.newest.Time) || th.newest.UID == 0 {
+					th.newest = e
+				}
+				depth++
+			}
+			children := append([]*email.ThreadNode{}, n.Children...)
+			sort.SliceStable(children, func(i, j int) bool {
+				return earliest(children[i], byUID).Before(earliest(children[j], byUID))
+			})
+			for _, child := range children {
+				walk(child, depth)
+			}
+		}
+		walk(root, 0)
+		if len(th.members) > 0 {
+			threads = append(threads, th)
+		}
+	}
+
+	for _, e := range list {
+		if !covered[e.UID] {
+			threads = append(threads, thread{
+				members: []email.Email{e},
+				depths:  []int{0},
+				newest:  e,
+			})
+		}
+	}
+
+	sort.SliceStable(threads, func(i, j int) bool {
+		if !threads[i].newest.Time.Equal(threads[j].newest.Time) {
+			return threads[i].newest.Time.After(threads[j].newest.Time)
+		}
+		return threads[i].newest.UID > threads[j].newest.UID
+	})
+
+	var rows []threadRow
+	for _, th := range threads {
+		key := th.members[0].UID
+		subject := th.members[0].Subject
+
+		if len(th.members) == 1 || !t.expanded[key] {
+			rows = append(rows, threadRow{
+				email:     th.newest,
+				key:       key,
+				subject:   subject,
+				members:   th.members,
+				collapsed: len(th.members) > 1,
+			})
+			continue
+		}
+
+		for i, e := range th.members {
+			rows = append(rows, threadRow{
+				email:   e,
+				depth:   th.depths[i],
+				key:     key,
+				subject: e.Subject,
+				members: th.members,
+			})
+		}
+	}
+	return rows
+}
+
+// Helper function to find the date a thread branch starts, so replies can
+// be put in order. Messages that are not loaded sort by their replies.
+func earliest(n *email.ThreadNode, byUID map[uint32]email.Email) time.Time {
+	if e, ok := byUID[n.UID]; ok {
+		return e.Time
+	}
+	var first time.Time
+	for _, child := range n.Children {
+		if d := earliest(child, byUID); !d.IsZero() && (first.IsZero() || d.Before(first)) {
+			first = d
+		}
+	}
+	return first
+}
+
+// Helper function to render the subject column of a threaded row
+func threadSubject(row threadRow) string {
+	switch {
+	case row.collapsed:
+		return fmt.Sprintf("[%d] %s", len(row.members), row.subject)
+	case row.depth > 0:
+		return strings.Repeat("  ", row.depth-1) + "└ " + row.subject
+	case len(row.members) > 1 && row.email.UID == row.key:
+		return "▾ " + row.subject
+	}
+	return row.subject
+}
+
+// Helper function to render the header block of an open conversation
+func threadHeaderView(members []email.Email, width int) string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, e := range members {
+		if name := e.From.Display(); name != "" && !seen[strings.ToLower(e.From.Addr)] {
+			seen[strings.ToLower(e.From.Addr)] = true
+			names = append(names, name)
+		}
+	}
+
+	lines := []string{
+		truncate("Subject: "+members[0].Subject, width),
+		fmt.Sprintf("Messages: %d", len(members)),
+		truncate("Participants: "+strings.Join(names, ", "), width),
+	}
+
+	return lipgloss.NewStyle().
+		Bold(true).
+		Padding(0, 0, 1, 0).
+		Render(strings.Join(lines, "\n"))
+}
+
+// Helper function to render every message of a conversation in order,
+// highlighting the given search words in the bodies
+func conversationView(members []email.Email, width int, words []string) string {
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(styles.Green))
+	rule := lipgloss.NewStyle().
+		Foreground(lipgloss.Color(styles.DarkGray)).
+		Render(strings.Repeat("─", max(width, 1)))
+
+	var parts []string
+	for i, e := range members {
+		date := e.Date
+		if len(date) >= 16 {
+			date = date[:16]
+		}
+		body := highlightText(e.Body, words)
+		if body == "" {
+			body = "Loading message..."
+		}
+
+		if i > 0 {
+			parts = append(parts, "", rule, "")
+		}
+		parts = append(parts, headerStyle.Render(fmt.Sprintf("%s · %s", e.From.String(), date)), "", body)
+	}
+	return strings.Join(parts, "\n")
+}
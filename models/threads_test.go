@@ -0,0 +1,43 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Zachkp/GoMail/email"
+)
+
+func TestThreadRowsOrderByTime(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	pacific := time.FixedZone("PST", -8*60*60)
+	at := func(uid uint32, when time.Time, refs ...string) email.Email {
+		return email.Email{
+			UID:        uid,
+			MessageID:  string(rune('a' + uid)),
+			References: refs,
+			Date:       when.Format("2006-01-02 15:04:05"),
+			Time:       when,
+		}
+	}
+
+	// Dates in the senders' own zones sort differently from the times
+	emails := []email.Email{
+		at(5, time.Date(2026, 3, 1, 3, 0, 0, 0, pacific)),
+		at(2, time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), "b"),
+		at(4, time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)),
+		at(3, time.Date(2026, 3, 1, 18, 0, 0, 0, tokyo), "b"),
+		at(1, time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)),
+	}
+
+	threads := ThreadState{enabled: true, roots: email.ThreadEmails(emails)}
+	threads.Fold(1)
+
+	var got []uint32
+	for _, row := range threads.Rows(emails) {
+		got = append(got, row.email.UID)
+	}
+	if want := []uint32{5, 1, 3, 2, 4}; !slices.Equal(got, want) {
+		t.Errorf("Rows = %v, want %v", got, want)
+	}
+}
@@ -7,6 +7,7 @@ var (
 	White    string = "#FFFFFF"
 	DarkGray string = "#3C3C3C"
 	Green    string = "#a6e3a1"
+	Red      string = "#f38ba8"
 )
 
 // Layout